@@ -4,26 +4,38 @@
 package common
 
 import (
+	"encoding/gob"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
 )
 
 // BuilderId is the common builder ID to all of these artifacts.
 const BuilderId = "packer.parallels"
 
-// These are the extensions of files and directories that are unnecessary for the function
-// of a Parallels virtual machine.
-var unnecessaryFiles = []string{"\\.log$", "\\.backup$", "\\.Backup$", "\\.app"}
+// ArtifactLogsDir returns the directory next to the output directory which
+// receives the files excluded from the artifact.
+func ArtifactLogsDir(dir string) string {
+	return filepath.Clean(dir) + "-logs"
+}
 
 // Artifact is the result of running the parallels builder, namely a set
 // of files associated with the resulting machine.
 type artifact struct {
-	dir string
-	f   []string
+	dir      string
+	f        []string
+	manifest *Manifest
+
+	// The UUID of the VM and whether it was left registered by the build
+	vmID       string
+	registered bool
+	// The driver used to tear down the VM, created on demand
+	driver Driver
 
 	// StateData should store data such as GeneratedData
 	// to be shared with post-processors
@@ -46,12 +58,6 @@ func NewArtifact(dir string, generatedData map[string]interface{}) (packersdk.Ar
 			}
 			return err
 		}
-		for _, unnecessaryFile := range unnecessaryFiles {
-			if unnecessary, _ := regexp.MatchString(unnecessaryFile, path); unnecessary {
-				return os.RemoveAll(path)
-			}
-		}
-
 		if !info.IsDir() {
 			files = append(files, path)
 		}
@@ -63,11 +69,27 @@ func NewArtifact(dir string, generatedData map[string]interface{}) (packersdk.Ar
 		return nil, err
 	}
 
-	return &artifact{
+	// The manifest is only available when the builder collected it
+	manifest, _ := generatedData["manifest"].(*Manifest)
+	if manifest != nil {
+		path, err := manifest.write(dir, files)
+		if err != nil {
+			return nil, fmt.Errorf("Error writing the build manifest: %s", err)
+		}
+		files = append(files, path)
+	}
+
+	a := &artifact{
 		dir:       dir,
 		f:         files,
+		manifest:  manifest,
 		StateData: generatedData,
-	}, nil
+	}
+	if manifest != nil {
+		a.vmID = manifest.UUID
+	}
+	a.registered, _ = generatedData["registered"].(bool)
+	return a, nil
 }
 
 func (*artifact) BuilderId() string {
@@ -78,18 +100,169 @@ func (a *artifact) Files() []string {
 	return a.f
 }
 
-func (*artifact) Id() string {
+func (a *artifact) Id() string {
+	if a.manifest != nil && a.manifest.UUID != "" {
+		return a.manifest.UUID
+	}
 	return "VM"
 }
 
 func (a *artifact) String() string {
-	return fmt.Sprintf("VM files in directory: %s", a.dir)
+	s := fmt.Sprintf("VM files in directory: %s", a.dir)
+
+	// A linked clone can't be used without the VM it was cloned from
+	if parent, ok := a.StateData["linked_clone_parent"].(string); ok && parent != "" {
+		s += fmt.Sprintf(" (linked clone of: %s)", parent)
+	}
+
+	if a.registered && a.manifest != nil {
+		kind := "VM"
+		if template, _ := a.StateData["template"].(bool); template {
+			kind = "template"
+		}
+		s += fmt.Sprintf(", registered as %s: %s %s", kind, a.manifest.VMName, a.manifest.UUID)
+	}
+	return s
 }
 
+func init() {
+	// The artifact state is encoded with gob to reach the post-processors
+	gob.Register(new(registryimage.Image))
+}
+
+// State returns the generated data and the fields of the build manifest,
+// named as in packer-manifest.json.
 func (a *artifact) State(name string) interface{} {
-	return a.StateData[name]
+	if name == registryimage.ArtifactStateURI {
+		return a.stateHCPPackerRegistryMetadata()
+	}
+	if a.manifest != nil {
+		if value, ok := a.manifest.stateData()[name]; ok {
+			return value
+		}
+	}
+	// The manifest itself is only exposed as JSON
+	if value, ok := a.StateData[name]; ok && name != "manifest" {
+		return value
+	}
+	return nil
 }
 
+// stateHCPPackerRegistryMetadata returns the image metadata for the HCP
+// Packer registry. The VM is identified by its UUID and located by the host
+// it was built on.
+func (a *artifact) stateHCPPackerRegistryMetadata() interface{} {
+	if a.manifest == nil {
+		return nil
+	}
+
+	labels := map[string]interface{}{
+		"guest_os":          a.manifest.GuestOS,
+		"architecture":      a.manifest.HostArch,
+		"parallels_version": a.manifest.ParallelsVersion,
+		"source_checksum":   a.manifest.SourceChecksum,
+	}
+	if len(a.manifest.Disks) > 0 {
+		labels["disk_format"] = a.manifest.Disks[0].Format
+	}
+
+	img, err := registryimage.FromArtifact(a,
+		registryimage.WithProvider("parallels"),
+		registryimage.WithRegion(a.manifest.Host),
+		registryimage.SetLabels(labels),
+	)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// Destroy tears down the VM if it is still registered in Parallels Desktop,
+// then removes its files. All the steps are attempted, and their failures
+// are reported together.
 func (a *artifact) Destroy() error {
-	return os.RemoveAll(a.dir)
+	var errs *packersdk.MultiError
+
+	if a.vmID != "" {
+		for _, err := range a.unregister() {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if err := os.RemoveAll(a.dir); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Error removing the VM files: %s", err))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// unregister stops the VM, deletes its snapshots and unregisters it.
+func (a *artifact) unregister() []error {
+	driver := a.driver
+	if driver == nil {
+		var err error
+		driver, err = NewDriver()
+		if err != nil {
+			if !a.registered {
+				// Nothing can be registered without Parallels Desktop
+				return nil
+			}
+			return []error{fmt.Errorf("Error creating the Parallels driver: %s", err)}
+		}
+	}
+
+	vm, err := driver.LookupVM(a.vmID)
+	if err != nil {
+		if a.registered {
+			return []error{fmt.Errorf("Error looking up the VM %s: %s", a.vmID, err)}
+		}
+		return nil
+	}
+
+	// Never touch a VM with the same UUID stored somewhere else
+	dir, _ := filepath.Abs(a.dir)
+	if !a.registered && !strings.HasPrefix(vm.Home, dir+string(os.PathSeparator)) {
+		return nil
+	}
+
+	var errs []error
+	if vm.Template {
+		// Templates can't run nor have snapshots
+		if err := driver.Prlctl("unregister", vm.ID); err != nil {
+			errs = append(errs, fmt.Errorf("Error unregistering the template: %s", err))
+		}
+		return errs
+	}
+
+	if running, err := driver.IsRunning(vm.ID); err != nil {
+		errs = append(errs, fmt.Errorf("Error checking the VM state: %s", err))
+	} else if running {
+		if err := driver.Stop(vm.ID); err != nil {
+			errs = append(errs, fmt.Errorf("Error stopping the VM: %s", err))
+		}
+	}
+
+	snapshots, err := driver.Snapshots(vm.ID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error listing the VM snapshots: %s", err))
+	}
+	ids := make([]string, 0, len(snapshots))
+	for id := range snapshots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := driver.Prlctl("snapshot-delete", vm.ID, "--id", id); err != nil {
+			errs = append(errs, fmt.Errorf("Error deleting the snapshot %s: %s", id, err))
+		}
+	}
+
+	if err := driver.Prlctl("unregister", vm.ID); err != nil {
+		errs = append(errs, fmt.Errorf("Error unregistering the VM: %s", err))
+	}
+
+	return errs
 }
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// PruneArtifact moves the files and directories of the output directory
+// matching the exclude patterns, and none of the keep patterns, to the logs
+// directory next to it, replacing the one of a previous build. It runs at
+// the end of the build, once the VM is shut down: the VM may still be
+// registered, with keep_registered or register_as_template, and Parallels
+// Desktop writes its log files again whenever it is started.
+func PruneArtifact(ui packersdk.Ui, dir string, exclude, keep []string) error {
+	logsDir := ArtifactLogsDir(dir)
+
+	var pruned []string
+	visit := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The path may be gone already, e.g. the <vm name>.app
+				// directory created by the VM console.
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if matchArtifactPattern(keep, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchArtifactPattern(exclude, rel) {
+			pruned = append(pruned, rel)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	}
+
+	if err := filepath.Walk(dir, visit); err != nil {
+		return err
+	}
+
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	if err := os.RemoveAll(logsDir); err != nil {
+		return fmt.Errorf("Error removing the logs of a previous build: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Moving files excluded from the artifact to: %s", logsDir))
+	for _, rel := range pruned {
+		ui.Message(rel)
+
+		dst := filepath.Join(logsDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(dir, filepath.FromSlash(rel)), dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error moving %s out of the artifact: %s", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// matchArtifactPattern reports whether the path, relative to the output
+// directory, matches one of the patterns. Patterns without a slash are
+// matched against the base name only.
+func matchArtifactPattern(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
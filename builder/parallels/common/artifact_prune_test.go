@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestPruneArtifact(t *testing.T) {
+	td := t.TempDir()
+
+	files := []string{
+		"foo.pvm/config.pvs",
+		"foo.pvm/parallels.log",
+		"foo.pvm/tools.log",
+		"foo.pvm/Snapshots.xml",
+		"foo.pvm/foo.app/Contents/Info.plist",
+		"foo.pvm/harddisk.hdd/harddisk.hds",
+	}
+	for _, f := range files {
+		path := filepath.Join(td, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte("foo"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: &out}
+
+	exclude := append(defaultArtifactExclude, "*.pvm/Snapshots.xml")
+	keep := []string{"parallels.log"}
+	if err := PruneArtifact(ui, td, exclude, keep); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, f := range []string{"foo.pvm/config.pvs", "foo.pvm/parallels.log", "foo.pvm/harddisk.hdd/harddisk.hds"} {
+		if _, err := os.Stat(filepath.Join(td, f)); err != nil {
+			t.Fatalf("%s should be kept: %s", f, err)
+		}
+	}
+	for _, f := range []string{"foo.pvm/tools.log", "foo.pvm/Snapshots.xml", "foo.pvm/foo.app/Contents/Info.plist"} {
+		if _, err := os.Stat(filepath.Join(td, f)); err == nil {
+			t.Fatalf("%s should be moved", f)
+		}
+		if _, err := os.Stat(filepath.Join(ArtifactLogsDir(td), f)); err != nil {
+			t.Fatalf("%s should be in the logs directory: %s", f, err)
+		}
+	}
+	for _, f := range []string{"foo.pvm/tools.log", "foo.pvm/Snapshots.xml", "foo.pvm/foo.app"} {
+		if !strings.Contains(out.String(), f) {
+			t.Fatalf("%s should be listed: %s", f, out.String())
+		}
+	}
+
+	// The logs directory is out of the artifact
+	if rel, err := filepath.Rel(td, ArtifactLogsDir(td)); err != nil || !strings.HasPrefix(rel, "..") {
+		t.Fatalf("bad logs directory: %s", ArtifactLogsDir(td))
+	}
+	a, err := NewArtifact(td, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(a.Files()) != 3 {
+		t.Fatalf("bad: %#v", a.Files())
+	}
+}
@@ -4,12 +4,20 @@
 package common
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	// Registers the types the plugin RPC encodes with gob
+	_ "github.com/hashicorp/packer-plugin-sdk/rpc"
 )
 
 func TestArtifact_impl(t *testing.T) {
@@ -48,3 +56,288 @@ func TestNewArtifact(t *testing.T) {
 		t.Fatalf("bad: should length have generated_data: %s", a.State("generated_data"))
 	}
 }
+
+func TestNewArtifact_linkedClone(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	generatedData := map[string]interface{}{"linked_clone_parent": "/path/to/parent.pvm"}
+	a, err := NewArtifact(td, generatedData)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if a.State("linked_clone_parent") != "/path/to/parent.pvm" {
+		t.Fatalf("bad: should record the parent: %s", a.State("linked_clone_parent"))
+	}
+	if !strings.Contains(a.String(), "/path/to/parent.pvm") {
+		t.Fatalf("bad: %s", a.String())
+	}
+}
+
+func TestNewArtifact_manifest(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	err = ioutil.WriteFile(filepath.Join(td, "a"), []byte("foo"), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &Manifest{
+		VMName: "foo",
+		UUID:   "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+	}
+	generatedData := map[string]interface{}{"manifest": manifest}
+	a, err := NewArtifact(td, generatedData)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if a.Id() != manifest.UUID {
+		t.Fatalf("bad id: %s", a.Id())
+	}
+	if len(a.Files()) != 2 {
+		t.Fatalf("should length 2: %d", len(a.Files()))
+	}
+	if a.State("vm_name") != "foo" {
+		t.Fatalf("bad: %#v", a.State("vm_name"))
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(td, ManifestFileName))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var written Manifest
+	if err := json.Unmarshal(raw, &written); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(written.Files) != 1 || written.Files[0].Path != "a" {
+		t.Fatalf("bad files: %#v", written.Files)
+	}
+	// sha256 of "foo"
+	if written.Files[0].SHA256 != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+		t.Fatalf("bad checksum: %s", written.Files[0].SHA256)
+	}
+}
+
+func TestArtifactState_hcpPackerRegistryMetadata(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Without a manifest
+	a, err := NewArtifact(td, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if a.State(registryimage.ArtifactStateURI) != nil {
+		t.Fatal("should not have registry metadata")
+	}
+
+	manifest := &Manifest{
+		UUID:             "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		GuestOS:          "ubuntu",
+		Host:             "builder.local",
+		HostArch:         "arm64",
+		ParallelsVersion: "19.1.0",
+		SourceChecksum:   "sha256:abc",
+		Disks:            []ManifestDisk{{Name: "hdd0", Format: "expanded"}},
+	}
+	a, err = NewArtifact(td, map[string]interface{}{"manifest": manifest})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	img, ok := a.State(registryimage.ArtifactStateURI).(*registryimage.Image)
+	if !ok {
+		t.Fatalf("bad: %#v", a.State(registryimage.ArtifactStateURI))
+	}
+	if img.ProviderName != "parallels" || img.ImageID != manifest.UUID || img.ProviderRegion != "builder.local" {
+		t.Fatalf("bad image: %s", img)
+	}
+
+	expected := map[string]string{
+		"guest_os":          "ubuntu",
+		"architecture":      "arm64",
+		"parallels_version": "19.1.0",
+		"disk_format":       "expanded",
+		"source_checksum":   "sha256:abc",
+	}
+	for k, v := range expected {
+		if img.Labels[k] != v {
+			t.Fatalf("bad label %s: %q", k, img.Labels[k])
+		}
+	}
+}
+
+func TestArtifactDestroy(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	driver := new(DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:   "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		Home: filepath.Join(td, "foo.pvm"),
+	}
+	driver.IsRunningReturn = true
+	driver.StopErr = errors.New("stop failed")
+	driver.SnapshotsResult = map[string]string{"{1d1c1b1a-0000-0000-0000-000000000000}": "clean"}
+
+	a := &artifact{
+		dir:    td,
+		vmID:   driver.LookupVMResult.ID,
+		driver: driver,
+	}
+
+	err = a.Destroy()
+	if err == nil || !strings.Contains(err.Error(), "stop failed") {
+		t.Fatalf("should report the stop failure: %v", err)
+	}
+
+	// The other steps are still run
+	if driver.StopName != driver.LookupVMResult.ID {
+		t.Fatalf("should stop the VM: %s", driver.StopName)
+	}
+	if len(driver.PrlctlCalls) != 2 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+	if driver.PrlctlCalls[0][0] != "snapshot-delete" || driver.PrlctlCalls[1][0] != "unregister" {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+	if _, err := os.Stat(td); !os.IsNotExist(err) {
+		t.Fatal("should remove the files")
+	}
+}
+
+func TestArtifactDestroy_otherVM(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// A VM with the same UUID, registered from another location
+	driver := new(DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:   "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		Home: "/path/to/other.pvm",
+	}
+
+	a := &artifact{
+		dir:    td,
+		vmID:   driver.LookupVMResult.ID,
+		driver: driver,
+	}
+
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(driver.PrlctlCalls) != 0 {
+		t.Fatalf("should not touch the other VM: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestArtifactString_registered(t *testing.T) {
+	a := &artifact{
+		dir:        "/path/to/output",
+		registered: true,
+		manifest: &Manifest{
+			VMName: "foo",
+			UUID:   "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		},
+		StateData: map[string]interface{}{"template": true},
+	}
+
+	expected := "VM files in directory: /path/to/output, registered as template: foo {8f8c6a5e-1b2c-4d3e-9f00-123456789abc}"
+	if a.String() != expected {
+		t.Fatalf("bad: %s", a.String())
+	}
+}
+
+// The artifact state is encoded with gob to reach the post-processors, which
+// fails on the types registered neither by the plugin RPC nor by the builder.
+func TestArtifactState_gob(t *testing.T) {
+	td := t.TempDir()
+	if err := os.WriteFile(filepath.Join(td, "a"), []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &Manifest{
+		VMName:           "foo",
+		UUID:             "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		MACAddresses:     []string{"001C42A1B2C3"},
+		GuestOS:          "ubuntu",
+		CPUs:             2,
+		MemoryMB:         4096,
+		Disks:            []ManifestDisk{{Name: "hdd0", Path: "foo.pvm/harddisk.hdd", Format: "expanded"}},
+		ParallelsVersion: "19.1.0",
+		Host:             "builder.local",
+		HostArch:         "arm64",
+		Source:           "ubuntu.iso",
+		SourceChecksum:   "sha256:abc",
+		BuildDuration:    "10m0s",
+	}
+	generatedData := map[string]interface{}{
+		"manifest":            manifest,
+		"registered":          true,
+		"template":            false,
+		"linked_clone_parent": "/path/to/parent.pvm",
+	}
+	a, err := NewArtifact(td, generatedData)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	names := []string{registryimage.ArtifactStateURI, "registered", "template", "linked_clone_parent"}
+	for name := range manifest.stateData() {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		value := a.State(name)
+		if value == nil {
+			t.Errorf("%s: should have state", name)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+			t.Errorf("%s: err: %s", name, err)
+			continue
+		}
+		var decoded interface{}
+		if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+			t.Errorf("%s: err: %s", name, err)
+		}
+	}
+
+	if a.State("cpus") != "2" {
+		t.Fatalf("bad cpus: %#v", a.State("cpus"))
+	}
+	if disks, _ := a.State("disks").([]string); len(disks) != 1 || disks[0] != "hdd0" {
+		t.Fatalf("bad disks: %#v", a.State("disks"))
+	}
+	files, _ := a.State("files").(*map[string]string)
+	if files == nil || (*files)["a"] != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+		t.Fatalf("bad files: %#v", a.State("files"))
+	}
+
+	var decoded Manifest
+	if err := json.Unmarshal([]byte(a.State("manifest").(string)), &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if decoded.Disks[0].Path != "foo.pvm/harddisk.hdd" {
+		t.Fatalf("bad manifest: %#v", decoded)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The directives are extensions of the boot command, which aren't typed but
+// performed by the plugin:
+//
+//	<click X,Y>          clicks at the coordinates, optionally followed by
+//	                     the button: left (default), right or middle.
+//	<clickText "TEXT">   clicks the center of the text recognized on the
+//	                     screen.
+//	<waitText "TEXT" 2m> waits for the text to appear on the screen,
+//	                     optionally followed by the timeout.
+//	<waitGone "TEXT" 1m> waits for the text to disappear from the screen,
+//	                     optionally followed by the timeout.
+var bootDirectiveRe = regexp.MustCompile(`<(click|clickText|waitText|waitGone)\s+((?:"(?:[^"\\]|\\.)*"|[^<>"])*)>`)
+
+var clickArgsRe = regexp.MustCompile(`^(\d+)\s*,\s*(\d+)(?:\s+(left|right|middle))?$`)
+
+var waitArgsRe = regexp.MustCompile(`^("(?:[^"\\]|\\.)*")(?:\s+(\S+))?$`)
+
+// defaultWaitTextTimeout is the timeout of the waitText and waitGone
+// directives, if none is given.
+const defaultWaitTextTimeout = 5 * time.Minute
+
+// The names of the boot command directives.
+const (
+	directiveClick     = "click"
+	directiveClickText = "clickText"
+	directiveWaitText  = "waitText"
+	directiveWaitGone  = "waitGone"
+)
+
+// bootDirective is a directive of the boot command, with its arguments.
+type bootDirective struct {
+	name    string
+	x, y    int
+	button  string
+	text    string
+	timeout time.Duration
+}
+
+func (d *bootDirective) String() string {
+	switch d.name {
+	case directiveClick:
+		return fmt.Sprintf("<click %d,%d %s>", d.x, d.y, d.button)
+	case directiveWaitText, directiveWaitGone:
+		return fmt.Sprintf("<%s %q %s>", d.name, d.text, d.timeout)
+	default:
+		return fmt.Sprintf("<%s %q>", d.name, d.text)
+	}
+}
+
+// sendsMouseEvents returns whether the directive clicks in the VM.
+func (d *bootDirective) sendsMouseEvents() bool {
+	return d.name == directiveClick || d.name == directiveClickText
+}
+
+// bootCommandPart is either text to type or a directive.
+type bootCommandPart struct {
+	text      string
+	directive *bootDirective
+}
+
+// splitBootCommand splits the boot command into the text to type and the
+// directives.
+func splitBootCommand(command string) ([]bootCommandPart, error) {
+	var parts []bootCommandPart
+	last := 0
+	for _, m := range bootDirectiveRe.FindAllStringSubmatchIndex(command, -1) {
+		if m[0] > last {
+			parts = append(parts, bootCommandPart{text: command[last:m[0]]})
+		}
+		last = m[1]
+
+		directive, err := parseBootDirective(command[m[2]:m[3]], strings.TrimSpace(command[m[4]:m[5]]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid directive %s: %s", command[m[0]:m[1]], err)
+		}
+		parts = append(parts, bootCommandPart{directive: directive})
+	}
+	if last < len(command) {
+		parts = append(parts, bootCommandPart{text: command[last:]})
+	}
+	return parts, nil
+}
+
+func parseBootDirective(name, args string) (*bootDirective, error) {
+	d := &bootDirective{name: name}
+	switch name {
+	case directiveClick:
+		m := clickArgsRe.FindStringSubmatch(args)
+		if m == nil {
+			return nil, fmt.Errorf("expected X,Y and optionally the button")
+		}
+		d.x, _ = strconv.Atoi(m[1])
+		d.y, _ = strconv.Atoi(m[2])
+		d.button = m[3]
+		if d.button == "" {
+			d.button = MouseButtonLeft
+		}
+	case directiveClickText:
+		text, err := parseDirectiveText(args)
+		if err != nil {
+			return nil, err
+		}
+		d.text = text
+	case directiveWaitText, directiveWaitGone:
+		m := waitArgsRe.FindStringSubmatch(args)
+		if m == nil {
+			return nil, fmt.Errorf("expected a quoted text and optionally the timeout")
+		}
+		text, err := parseDirectiveText(m[1])
+		if err != nil {
+			return nil, err
+		}
+		d.text = text
+		d.timeout = defaultWaitTextTimeout
+		if m[2] != "" {
+			d.timeout, err = time.ParseDuration(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout: %s", err)
+			}
+			if d.timeout <= 0 {
+				return nil, fmt.Errorf("the timeout must be positive")
+			}
+		}
+	}
+	return d, nil
+}
+
+func parseDirectiveText(arg string) (string, error) {
+	text, err := strconv.Unquote(arg)
+	if err != nil || !strings.HasPrefix(arg, `"`) {
+		return "", fmt.Errorf("expected a quoted text")
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("the text must not be empty")
+	}
+	return text, nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitBootCommand(t *testing.T) {
+	parts, err := splitBootCommand(`a<enter><click 10, 20><clickText "Agree > Continue">b<click 5,6 right>`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []bootCommandPart{
+		{text: "a<enter>"},
+		{directive: &bootDirective{name: directiveClick, x: 10, y: 20, button: MouseButtonLeft}},
+		{directive: &bootDirective{name: directiveClickText, text: "Agree > Continue"}},
+		{text: "b"},
+		{directive: &bootDirective{name: directiveClick, x: 5, y: 6, button: MouseButtonRight}},
+	}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Fatalf("bad: %#v", parts)
+	}
+}
+
+func TestSplitBootCommand_invalid(t *testing.T) {
+	commands := []string{
+		`<click 10>`,
+		`<click 10,20 double>`,
+		`<clickText Continue>`,
+		`<clickText "">`,
+		`<waitText Continue>`,
+		`<waitText "Continue" soon>`,
+		`<waitGone "Continue" -1m>`,
+	}
+	for _, command := range commands {
+		if _, err := splitBootCommand(command); err == nil {
+			t.Errorf("%s: should error", command)
+		}
+	}
+}
+
+func TestSplitBootCommand_wait(t *testing.T) {
+	parts, err := splitBootCommand(`<waitText "Select language" 2m><enter><waitGone "Loading...">`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []bootCommandPart{
+		{directive: &bootDirective{name: directiveWaitText, text: "Select language", timeout: 2 * time.Minute}},
+		{text: "<enter>"},
+		{directive: &bootDirective{name: directiveWaitGone, text: "Loading...", timeout: defaultWaitTextTimeout}},
+	}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Fatalf("bad: %#v", parts)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// DefaultBootKeyInterval is the delay after each key event of the boot command.
+const DefaultBootKeyInterval = 100 * time.Millisecond
+
+// BootKeyboardConfig contains the configuration of the keyboard used to type
+// the boot command.
+type BootKeyboardConfig struct {
+	// The keyboard layout the guest expects while the boot command is typed.
+	// The characters of the boot command are translated to the keys, and the
+	// Shift and AltGr modifiers, which produce them with this layout. Dead
+	// keys are followed by a space, so that the character itself is typed.
+	// Possible values are: us, us-intl, uk, de, fr. Defaults to us.
+	BootKeyboardLayout string `mapstructure:"boot_keyboard_layout" required:"false"`
+	// The delay after each key event while the boot command is typed. The
+	// keys between two waits are sent to the VM at once, the delay is applied
+	// by Parallels Desktop. Increase it for guests dropping keys. Defaults to
+	// 100ms.
+	BootKeyInterval time.Duration `mapstructure:"boot_key_interval" required:"false"`
+}
+
+// Prepare sets the default keyboard layout and key interval, and validates
+// them.
+func (c *BootKeyboardConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	if c.BootKeyboardLayout == "" {
+		c.BootKeyboardLayout = DefaultKeyboardLayout
+	}
+
+	if c.BootKeyInterval == 0 {
+		c.BootKeyInterval = DefaultBootKeyInterval
+	}
+	if c.BootKeyInterval < 0 {
+		errs = append(errs, fmt.Errorf("boot_key_interval must not be negative"))
+	}
+
+	if _, ok := keyboardLayouts[c.BootKeyboardLayout]; !ok {
+		errs = append(errs,
+			fmt.Errorf("invalid value for boot_keyboard_layout: %s. Allowed values are : %v", c.BootKeyboardLayout, KeyboardLayouts()))
+	}
+
+	return errs
+}
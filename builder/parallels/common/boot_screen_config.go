@@ -8,7 +8,10 @@ package common
 
 import (
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -23,12 +26,76 @@ type BootScreenConfig struct {
 	ScreenName string `mapstructure:"screen_name"`
 	// Strings present in the screen to identify this screen
 	MatchingStrings []string `mapstructure:"matching_strings"`
+	// If true, the screen is identified when any of the matching strings is
+	// present, instead of all of them. Default value is false
+	MatchAny bool `mapstructure:"match_any"`
+	// The number of edits, i.e. inserted, deleted or replaced characters,
+	// tolerated between each matching string and the recognized text, e.g. 1
+	// for "Contniue" to match "continue". When set, the strings are also
+	// matched regardless of the characters OCR commonly confuses: l, 1, I
+	// and O, 0. Default value is 0
+	FuzzyDistance int `mapstructure:"fuzzy_distance"`
+	// The minimum confidence, from 0 to 1, of the recognized words used to
+	// identify this screen. Only applies to the OCR libraries providing it:
+	// vision, tesseract, command and http. Default value is 0
+	MinConfidence float64 `mapstructure:"min_confidence"`
+	// Strings which must not be present in the screen to identify this screen
+	ExcludeStrings []string `mapstructure:"exclude_strings"`
+	// Regular expression, case insensitive, the text of the screen must
+	// match to identify this screen
+	MatchingRegex string `mapstructure:"matching_regex"`
+	// Region of the screen the text is matched in, as [x, y, width, height]
+	// relative to the size of the screen, from 0 to 1, e.g.
+	// [0.25, 0, 0.75, 1] for the main pane right of a sidebar. The texts
+	// whose center is out of the region are ignored for this screen. By
+	// default, the text of the whole screen is matched
+	Region []float64 `mapstructure:"region"`
+	// Path of a reference image of the screen, for the "image" OCR library.
+	// The screen is identified when the screenshot looks like this image
+	MatchImage string `mapstructure:"match_image"`
+	// Region of the screenshot compared to the reference image, as
+	// [x, y, width, height] in pixels. If the reference image has the size of
+	// the screenshot, its same region is compared, otherwise the reference
+	// image is the region itself. By default, the whole screenshot is compared
+	MatchRegion []int `mapstructure:"match_region"`
+	// Similarity, from 0 to 1, between the screenshot and the reference
+	// image needed to identify the screen. Default value is 0.9
+	Threshold float64 `mapstructure:"threshold"`
+	// Screens with a higher priority are matched first. Screens with the
+	// same priority are matched in the order of their declaration.
+	// Default value is 0
+	Priority int `mapstructure:"priority"`
 	// Specifies if the current screen is the last screen
 	// Screen based boot will stop after this screen
 	IsLastScreen bool `mapstructure:"is_last_screen"`
 	// If true, the screen will be deleted after first execution
 	// Default value is false
 	ExecuteOnlyOnce bool `mapstructure:"execute_only_once"`
+	// Specifies if the screen shows a failure, e.g. "An error occurred while
+	// installing". The build fails when this screen is identified
+	IsFailureScreen bool `mapstructure:"is_failure_screen"`
+	// The maximum time the screen stays on, e.g. "10m". The build fails
+	// when the screen is still identified after it. Default value is 0,
+	// no timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// The maximum number of times the screen shows up. The build fails
+	// when it shows up once more, which usually means the boot is looping.
+	// Default value is 0, no limit
+	MaxRepeats int `mapstructure:"max_repeats"`
+	// The names of the screens allowed to follow this screen, e.g. to
+	// tell an installer looping on a retry dialog. The build fails on any
+	// other transition. The empty and failure screens may always follow.
+	// Once any screen declares its next screens, the screen based boot must
+	// start on the first declared screen, other than the empty and failure
+	// screens, and every screen must be reachable from it and lead to a
+	// last screen. By default, any screen may follow
+	NextScreens []string `mapstructure:"next_screens"`
+	// The maximum number of visits of the screen, i.e. of times it shows up
+	// after another screen. Unlike max_repeats, the screen showing up again
+	// after the empty screen, e.g. a blank screen while loading, isn't
+	// visited again. The build fails with the visited screens when it is
+	// visited once more. Default value is 0, no limit
+	MaxVisits int `mapstructure:"max_visits"`
 }
 
 func (c *BootScreenConfig) Prepare(ctx *interpolate.Context) (errs []error) {
@@ -44,6 +111,69 @@ func (c *BootScreenConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 		c.ExecuteOnlyOnce = false
 	}
 
+	if c.IsFailureScreen && c.IsLastScreen {
+		errs = append(errs, fmt.Errorf("screen %q can't be both a failure and the last screen", c.ScreenName))
+	}
+
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout of screen %q must not be negative", c.ScreenName))
+	}
+
+	if c.MaxRepeats < 0 {
+		errs = append(errs, fmt.Errorf("max_repeats of screen %q must not be negative", c.ScreenName))
+	}
+
+	if len(c.NextScreens) > 0 && (c.IsEmpty() || c.IsFailureScreen) {
+		errs = append(errs, fmt.Errorf("next_screens of screen %q: the empty and failure screens may be followed by any screen", c.ScreenName))
+	}
+
+	if c.MaxVisits < 0 {
+		errs = append(errs, fmt.Errorf("max_visits of screen %q must not be negative", c.ScreenName))
+	}
+
+	if c.FuzzyDistance < 0 {
+		errs = append(errs, fmt.Errorf("fuzzy_distance of screen %q must not be negative", c.ScreenName))
+	}
+
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		errs = append(errs, fmt.Errorf("min_confidence of screen %q must be from 0 to 1", c.ScreenName))
+	}
+
+	if c.MatchImage != "" {
+		if _, err := os.Stat(c.MatchImage); err != nil {
+			errs = append(errs, fmt.Errorf("match_image of screen %q: %s", c.ScreenName, err))
+		}
+	}
+
+	if len(c.MatchRegion) > 0 {
+		if c.MatchImage == "" {
+			errs = append(errs, fmt.Errorf("match_region of screen %q requires match_image", c.ScreenName))
+		}
+		if len(c.MatchRegion) != 4 || c.MatchRegion[0] < 0 || c.MatchRegion[1] < 0 ||
+			c.MatchRegion[2] <= 0 || c.MatchRegion[3] <= 0 {
+			errs = append(errs, fmt.Errorf("match_region of screen %q must be [x, y, width, height], "+
+				"with a positive width and height", c.ScreenName))
+		}
+	}
+
+	if len(c.Region) > 0 {
+		if len(c.Region) != 4 || c.Region[0] < 0 || c.Region[1] < 0 || c.Region[2] <= 0 || c.Region[3] <= 0 ||
+			c.Region[0]+c.Region[2] > 1 || c.Region[1]+c.Region[3] > 1 {
+			errs = append(errs, fmt.Errorf("region of screen %q must be [x, y, width, height] within the screen, "+
+				"from 0 to 1, with a positive width and height", c.ScreenName))
+		}
+	}
+
+	if c.Threshold < 0 || c.Threshold > 1 {
+		errs = append(errs, fmt.Errorf("threshold of screen %q must be from 0 to 1", c.ScreenName))
+	}
+
+	if c.MatchingRegex != "" {
+		if _, err := compileMatchingRegex(c.MatchingRegex); err != nil {
+			errs = append(errs, fmt.Errorf("invalid matching_regex of screen %q: %s", c.ScreenName, err))
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs
 	}
@@ -52,10 +182,24 @@ func (c *BootScreenConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 	for i, matchingString := range c.MatchingStrings {
 		c.MatchingStrings[i] = strings.ToLower(matchingString)
 	}
+	for i, excludeString := range c.ExcludeStrings {
+		c.ExcludeStrings[i] = strings.ToLower(excludeString)
+	}
 
 	return nil
 }
 
+// IsEmpty returns true if the screen has nothing to identify it. The empty
+// screen is the one identified when no other screen matches.
+func (c *BootScreenConfig) IsEmpty() bool {
+	return len(c.MatchingStrings) == 0 && len(c.ExcludeStrings) == 0 && c.MatchingRegex == "" &&
+		c.MatchImage == ""
+}
+
+func compileMatchingRegex(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + expr)
+}
+
 func (c *BootScreenConfig) FlatBootCommand() string {
 	return strings.Join(c.BootCommand, "")
 }
@@ -10,13 +10,28 @@ import (
 // FlatBootScreenConfig is an auto-generated flat version of BootScreenConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatBootScreenConfig struct {
-	BootGroupInterval *string  `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait          *string  `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand       []string `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	ScreenName        *string  `mapstructure:"screen_name" cty:"screen_name" hcl:"screen_name"`
-	MatchingStrings   []string `mapstructure:"matching_strings" cty:"matching_strings" hcl:"matching_strings"`
-	IsLastScreen      *bool    `mapstructure:"is_last_screen" cty:"is_last_screen" hcl:"is_last_screen"`
-	ExecuteOnlyOnce   *bool    `mapstructure:"execute_only_once" cty:"execute_only_once" hcl:"execute_only_once"`
+	BootGroupInterval *string   `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait          *string   `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand       []string  `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	ScreenName        *string   `mapstructure:"screen_name" cty:"screen_name" hcl:"screen_name"`
+	MatchingStrings   []string  `mapstructure:"matching_strings" cty:"matching_strings" hcl:"matching_strings"`
+	MatchAny          *bool     `mapstructure:"match_any" cty:"match_any" hcl:"match_any"`
+	FuzzyDistance     *int      `mapstructure:"fuzzy_distance" cty:"fuzzy_distance" hcl:"fuzzy_distance"`
+	MinConfidence     *float64  `mapstructure:"min_confidence" cty:"min_confidence" hcl:"min_confidence"`
+	ExcludeStrings    []string  `mapstructure:"exclude_strings" cty:"exclude_strings" hcl:"exclude_strings"`
+	MatchingRegex     *string   `mapstructure:"matching_regex" cty:"matching_regex" hcl:"matching_regex"`
+	Region            []float64 `mapstructure:"region" cty:"region" hcl:"region"`
+	MatchImage        *string   `mapstructure:"match_image" cty:"match_image" hcl:"match_image"`
+	MatchRegion       []int     `mapstructure:"match_region" cty:"match_region" hcl:"match_region"`
+	Threshold         *float64  `mapstructure:"threshold" cty:"threshold" hcl:"threshold"`
+	Priority          *int      `mapstructure:"priority" cty:"priority" hcl:"priority"`
+	IsLastScreen      *bool     `mapstructure:"is_last_screen" cty:"is_last_screen" hcl:"is_last_screen"`
+	ExecuteOnlyOnce   *bool     `mapstructure:"execute_only_once" cty:"execute_only_once" hcl:"execute_only_once"`
+	IsFailureScreen   *bool     `mapstructure:"is_failure_screen" cty:"is_failure_screen" hcl:"is_failure_screen"`
+	Timeout           *string   `mapstructure:"timeout" cty:"timeout" hcl:"timeout"`
+	MaxRepeats        *int      `mapstructure:"max_repeats" cty:"max_repeats" hcl:"max_repeats"`
+	NextScreens       []string  `mapstructure:"next_screens" cty:"next_screens" hcl:"next_screens"`
+	MaxVisits         *int      `mapstructure:"max_visits" cty:"max_visits" hcl:"max_visits"`
 }
 
 // FlatMapstructure returns a new FlatBootScreenConfig.
@@ -36,8 +51,23 @@ func (*FlatBootScreenConfig) HCL2Spec() map[string]hcldec.Spec {
 		"boot_command":           &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
 		"screen_name":            &hcldec.AttrSpec{Name: "screen_name", Type: cty.String, Required: false},
 		"matching_strings":       &hcldec.AttrSpec{Name: "matching_strings", Type: cty.List(cty.String), Required: false},
+		"match_any":              &hcldec.AttrSpec{Name: "match_any", Type: cty.Bool, Required: false},
+		"fuzzy_distance":         &hcldec.AttrSpec{Name: "fuzzy_distance", Type: cty.Number, Required: false},
+		"min_confidence":         &hcldec.AttrSpec{Name: "min_confidence", Type: cty.Number, Required: false},
+		"exclude_strings":        &hcldec.AttrSpec{Name: "exclude_strings", Type: cty.List(cty.String), Required: false},
+		"matching_regex":         &hcldec.AttrSpec{Name: "matching_regex", Type: cty.String, Required: false},
+		"region":                 &hcldec.AttrSpec{Name: "region", Type: cty.List(cty.Number), Required: false},
+		"match_image":            &hcldec.AttrSpec{Name: "match_image", Type: cty.String, Required: false},
+		"match_region":           &hcldec.AttrSpec{Name: "match_region", Type: cty.List(cty.Number), Required: false},
+		"threshold":              &hcldec.AttrSpec{Name: "threshold", Type: cty.Number, Required: false},
+		"priority":               &hcldec.AttrSpec{Name: "priority", Type: cty.Number, Required: false},
 		"is_last_screen":         &hcldec.AttrSpec{Name: "is_last_screen", Type: cty.Bool, Required: false},
 		"execute_only_once":      &hcldec.AttrSpec{Name: "execute_only_once", Type: cty.Bool, Required: false},
+		"is_failure_screen":      &hcldec.AttrSpec{Name: "is_failure_screen", Type: cty.Bool, Required: false},
+		"timeout":                &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
+		"max_repeats":            &hcldec.AttrSpec{Name: "max_repeats", Type: cty.Number, Required: false},
+		"next_screens":           &hcldec.AttrSpec{Name: "next_screens", Type: cty.List(cty.String), Required: false},
+		"max_visits":             &hcldec.AttrSpec{Name: "max_visits", Type: cty.Number, Required: false},
 	}
 	return s
 }
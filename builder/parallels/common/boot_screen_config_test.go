@@ -0,0 +1,157 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestBootScreenConfigPrepare_matching(t *testing.T) {
+	c := &BootScreenConfig{
+		ScreenName:     "keyboard",
+		ExcludeStrings: []string{"Language"},
+		MatchingRegex:  `macos \d+`,
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.ExcludeStrings[0] != "language" {
+		t.Fatalf("bad: %#v", c.ExcludeStrings)
+	}
+
+	c.MatchingRegex = `macos (`
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBootScreenConfigPrepare_failure(t *testing.T) {
+	c := &BootScreenConfig{
+		ScreenName:      "error",
+		MatchingStrings: []string{"An error occurred while installing"},
+		IsFailureScreen: true,
+		Timeout:         5 * time.Minute,
+		MaxRepeats:      3,
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c.IsLastScreen = true
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c.IsLastScreen = false
+	c.Timeout = -time.Minute
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c.Timeout = 0
+	c.MaxRepeats = -1
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c.MaxRepeats = 0
+	c.MaxVisits = -1
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c.MaxVisits = 0
+	c.NextScreens = []string{"language"}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBootScreenConfigPrepare_fuzzy(t *testing.T) {
+	c := &BootScreenConfig{
+		ScreenName:      "continue",
+		MatchingStrings: []string{"Continue"},
+		FuzzyDistance:   1,
+		MinConfidence:   0.5,
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c.FuzzyDistance = -1
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c.FuzzyDistance = 0
+	c.MinConfidence = 1.5
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBootScreenConfigPrepare_region(t *testing.T) {
+	c := &BootScreenConfig{
+		ScreenName:      "continue",
+		MatchingStrings: []string{"Continue"},
+		Region:          []float64{0.25, 0, 0.75, 1},
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	regions := [][]float64{
+		{0.25, 0, 0.75},
+		{0.5, 0, 0.75, 1},
+		{0, 0, 0, 1},
+		{-0.1, 0, 0.5, 0.5},
+	}
+	for _, region := range regions {
+		c.Region = region
+		if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+			t.Errorf("%v: should have error", region)
+		}
+	}
+}
+
+func TestBootScreenConfigPrepare_matchImage(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "language.png")
+	if err := os.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &BootScreenConfig{
+		ScreenName:  "language",
+		MatchImage:  image,
+		MatchRegion: []int{0, 0, 100, 50},
+		Threshold:   0.8,
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	invalid := []func(c *BootScreenConfig){
+		func(c *BootScreenConfig) { c.MatchImage = image + ".missing" },
+		func(c *BootScreenConfig) { c.MatchRegion = []int{0, 0, 100} },
+		func(c *BootScreenConfig) { c.MatchRegion = []int{0, 0, 0, 50} },
+		func(c *BootScreenConfig) { c.Threshold = 1.5 },
+		func(c *BootScreenConfig) { c.MatchImage = "" },
+	}
+	for i, f := range invalid {
+		c := &BootScreenConfig{
+			ScreenName:  "language",
+			MatchImage:  image,
+			MatchRegion: []int{0, 0, 100, 50},
+		}
+		f(c)
+		if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+			t.Errorf("%d: should have error", i)
+		}
+	}
+}
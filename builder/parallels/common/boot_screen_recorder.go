@@ -0,0 +1,214 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// The similarity of the words of a capture and of a screen, from 0 to
+	// 1, needed for the capture to be of this screen.
+	recordSimilarity = 0.6
+	// The maximum number of matching strings proposed for a screen.
+	recordMatchingStrings = 3
+	// The minimum length of the words proposed as matching strings, as
+	// shorter words are seldom distinctive.
+	recordMinWordLength = 3
+)
+
+// bootScreenRecorder clusters the screenshots captured while the operator
+// drives the VM into distinct screens, and proposes their boot_screen_config.
+type bootScreenRecorder struct {
+	screens []*recordedScreen
+	last    *recordedScreen
+}
+
+type recordedScreen struct {
+	hash screenHash
+	// The first line of text of the first capture
+	title string
+	// The words of any capture, and of all the captures of the screen
+	words       map[string]bool
+	commonWords map[string]bool
+	captures    int
+}
+
+// add clusters the capture with the screen it looks like, if any, or
+// records a new screen. It returns whether the screen is new.
+func (r *bootScreenRecorder) add(hash screenHash, texts ScreenTexts) bool {
+	words := recordWords(texts)
+
+	var best *recordedScreen
+	bestSimilarity := 0.0
+	for _, screen := range r.screens {
+		similarity := 0.0
+		if len(words) == 0 && len(screen.words) == 0 {
+			// Screens without text are told apart by their looks
+			if hash.distance(screen.hash) <= screenHashTolerance {
+				similarity = 1
+			}
+		} else {
+			similarity = jaccard(words, screen.words)
+		}
+		if similarity >= recordSimilarity && similarity > bestSimilarity {
+			best, bestSimilarity = screen, similarity
+		}
+	}
+
+	if best != nil {
+		best.captures++
+		for word := range words {
+			best.words[word] = true
+		}
+		for word := range best.commonWords {
+			if !words[word] {
+				delete(best.commonWords, word)
+			}
+		}
+		r.last = best
+		return false
+	}
+
+	screen := &recordedScreen{
+		hash:        hash,
+		words:       words,
+		commonWords: make(map[string]bool),
+		captures:    1,
+	}
+	for word := range words {
+		screen.commonWords[word] = true
+	}
+	if len(texts) > 0 {
+		screen.title = texts[0].Text
+	}
+	r.screens = append(r.screens, screen)
+	r.last = screen
+	return true
+}
+
+// matchingStrings proposes the words on all the captures of the screen, and
+// on none of the other screens. The longest words are proposed first.
+func (r *bootScreenRecorder) matchingStrings(screen *recordedScreen) []string {
+	var distinct []string
+	for word := range screen.commonWords {
+		found := false
+		for _, other := range r.screens {
+			if other != screen && other.words[word] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			distinct = append(distinct, word)
+		}
+	}
+
+	sort.Slice(distinct, func(i, j int) bool {
+		if len(distinct[i]) != len(distinct[j]) {
+			return len(distinct[i]) > len(distinct[j])
+		}
+		return distinct[i] < distinct[j]
+	})
+	if len(distinct) > recordMatchingStrings {
+		distinct = distinct[:recordMatchingStrings]
+	}
+	return distinct
+}
+
+// writeHCL writes the proposed boot_screen_config of the screens, in the
+// order they were first captured. The last captured screen is proposed as
+// the last screen.
+func (r *bootScreenRecorder) writeHCL(fileName string) error {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	body.AppendUnstructuredTokens(commentTokens("# Recorded screens, to paste into the source block once edited"))
+	body.AppendNewline()
+
+	for i, screen := range r.screens {
+		if i > 0 {
+			body.AppendNewline()
+		}
+
+		comment := fmt.Sprintf("# Screen %d, captured once", i+1)
+		if screen.captures > 1 {
+			comment = fmt.Sprintf("# Screen %d, captured %d times", i+1, screen.captures)
+		}
+		if screen.title != "" {
+			comment += fmt.Sprintf(": %q", screen.title)
+		}
+		body.AppendUnstructuredTokens(commentTokens(comment))
+
+		matchingStrings := r.matchingStrings(screen)
+		if len(matchingStrings) == 0 {
+			body.AppendUnstructuredTokens(commentTokens("# No distinctive words found, set matching_strings or match_image"))
+		}
+
+		block := body.AppendNewBlock("boot_screen_config", nil).Body()
+		block.SetAttributeValue("screen_name", cty.StringVal(fmt.Sprintf("screen_%d", i+1)))
+		values := make([]cty.Value, 0, len(matchingStrings))
+		for _, s := range matchingStrings {
+			values = append(values, cty.StringVal(s))
+		}
+		if len(values) > 0 {
+			block.SetAttributeValue("matching_strings", cty.ListVal(values))
+		} else {
+			block.SetAttributeValue("matching_strings", cty.ListValEmpty(cty.String))
+		}
+		block.AppendUnstructuredTokens(commentTokens("# The keys typed on this screen"))
+		block.SetAttributeValue("boot_command", cty.ListValEmpty(cty.String))
+		if screen == r.last {
+			block.SetAttributeValue("is_last_screen", cty.True)
+		}
+	}
+
+	return os.WriteFile(fileName, f.Bytes(), 0644)
+}
+
+func commentTokens(comment string) hclwrite.Tokens {
+	return hclwrite.Tokens{{
+		Type:  hclsyntax.TokenComment,
+		Bytes: []byte(comment + "\n"),
+	}}
+}
+
+// recordWords returns the distinct words of the texts, lowercased, which may
+// be proposed as matching strings.
+func recordWords(texts ScreenTexts) map[string]bool {
+	words := make(map[string]bool)
+	for _, text := range texts {
+		for _, word := range strings.Fields(strings.ToLower(text.Text)) {
+			word = strings.TrimFunc(word, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if len([]rune(word)) >= recordMinWordLength {
+				words[word] = true
+			}
+		}
+	}
+	return words
+}
+
+// jaccard returns the size of the intersection of the sets over the size of
+// their union, from 0 to 1.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	intersection := 0
+	for word := range a {
+		if b[word] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
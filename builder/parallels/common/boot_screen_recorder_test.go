@@ -0,0 +1,101 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestBootScreenRecorder(t *testing.T) {
+	recorder := &bootScreenRecorder{}
+	hash := make(screenHash, imageGridSize*imageGridSize)
+
+	captures := []struct {
+		texts ScreenTexts
+		isNew bool
+	}{
+		{ScreenTexts{{Text: "Select Your Country or Region"}, {Text: "France"}, {Text: "Continue"}}, true},
+		// The same screen, with another country selected
+		{ScreenTexts{{Text: "Select Your Country or Region"}, {Text: "Germany"}, {Text: "Continue"}}, false},
+		{ScreenTexts{{Text: "Terms and Conditions"}, {Text: "Agree"}, {Text: "Continue"}}, true},
+		{ScreenTexts{{Text: "Select Your Country or Region"}, {Text: "Germany"}, {Text: "Continue"}}, false},
+	}
+	for i, c := range captures {
+		if isNew := recorder.add(hash, c.texts); isNew != c.isNew {
+			t.Fatalf("%d: bad: %t", i, isNew)
+		}
+	}
+
+	if len(recorder.screens) != 2 || recorder.screens[0].captures != 3 {
+		t.Fatalf("bad screens: %#v", recorder.screens)
+	}
+	if recorder.last != recorder.screens[0] {
+		t.Fatal("the last screen should be the first one")
+	}
+
+	// The words of all the captures of the screen, and of no other screen
+	expected := []string{"country", "region", "select"}
+	if s := recorder.matchingStrings(recorder.screens[0]); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("bad: %#v", s)
+	}
+	expected = []string{"conditions", "agree", "terms"}
+	if s := recorder.matchingStrings(recorder.screens[1]); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("bad: %#v", s)
+	}
+}
+
+func TestBootScreenRecorder_noText(t *testing.T) {
+	recorder := &bootScreenRecorder{}
+	black := make(screenHash, imageGridSize*imageGridSize)
+	white := make(screenHash, imageGridSize*imageGridSize)
+	for i := range white {
+		white[i] = 255
+	}
+
+	if !recorder.add(black, nil) || recorder.add(black, nil) || !recorder.add(white, nil) {
+		t.Fatalf("bad screens: %#v", recorder.screens)
+	}
+}
+
+func TestBootScreenRecorder_writeHCL(t *testing.T) {
+	recorder := &bootScreenRecorder{}
+	hash := make(screenHash, imageGridSize*imageGridSize)
+	recorder.add(hash, ScreenTexts{{Text: "Select Your Country or Region"}, {Text: "Continue"}})
+	recorder.add(hash, ScreenTexts{{Text: "Terms and \"Conditions\""}, {Text: "Continue"}})
+	recorder.add(hash, ScreenTexts{{Text: "Continue"}})
+
+	fileName := filepath.Join(t.TempDir(), "boot_screen_config.pkr.hcl")
+	if err := recorder.writeHCL(fileName); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, diags := hclparse.NewParser().ParseHCLFile(fileName)
+	if diags.HasErrors() {
+		t.Fatalf("err: %s", diags)
+	}
+	content, diags := f.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "boot_screen_config"}},
+	})
+	if diags.HasErrors() {
+		t.Fatalf("err: %s", diags)
+	}
+	if len(content.Blocks) != 3 {
+		t.Fatalf("bad blocks: %#v", content.Blocks)
+	}
+
+	attrs, diags := content.Blocks[2].Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatalf("err: %s", diags)
+	}
+	for _, name := range []string{"screen_name", "matching_strings", "boot_command", "is_last_screen"} {
+		if _, ok := attrs[name]; !ok {
+			t.Errorf("%s is missing", name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// These are the different valid values for "clone_mode" which determine
+// how the source VM is imported.
+const (
+	CloneModeFull   string = "full"
+	CloneModeLinked        = "linked"
+)
+
+// CloneConfig contains the configuration for importing the source VM.
+type CloneConfig struct {
+	// The way the source VM is cloned into the output directory. Valid
+	// options are "full" and "linked". A "full" clone copies all the files
+	// of the source VM. A "linked" clone is based on a snapshot of the source
+	// VM and only stores the changes made during the build, which is much
+	// faster, but the resulting VM depends on the source VM files.
+	// Unless `source_snapshot` is set, the build takes a `packer-<vm_name>`
+	// snapshot of the source VM, which the linked clone depends on: it is
+	// kept in the source VM, and only deleted once the clone is flattened
+	// or when the build fails. With `source_url`, a "linked" clone requires
+	// `flatten_linked_clone`, as the extracted source VM is removed at the
+	// end of the build, or shared by the other builds once cached.
+	// Defaults to "full".
+	CloneMode string `mapstructure:"clone_mode" required:"false"`
+	// If true, the linked clone is turned into a standalone VM at the end of
+	// the build by merging the data of its parent into its virtual disks. This
+	// is useful when the resulting VM has to be distributed. Only valid when
+	// clone_mode is "linked". Defaults to false.
+	FlattenLinkedClone bool `mapstructure:"flatten_linked_clone" required:"false"`
+}
+
+// Prepare validates the clone mode and sets its default value.
+func (c *CloneConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	if c.CloneMode == "" {
+		c.CloneMode = CloneModeFull
+	}
+
+	if c.CloneMode != CloneModeFull && c.CloneMode != CloneModeLinked {
+		errs = append(errs,
+			fmt.Errorf("clone_mode is invalid. Must be one of: %v",
+				[]string{CloneModeFull, CloneModeLinked}))
+	}
+
+	if c.FlattenLinkedClone && c.CloneMode != CloneModeLinked {
+		errs = append(errs,
+			errors.New("flatten_linked_clone can only be used when clone_mode is \"linked\""))
+	}
+
+	return errs
+}
+
+// IsLinked reports whether the source VM is imported as a linked clone.
+func (c *CloneConfig) IsLinked() bool {
+	return c.CloneMode == CloneModeLinked
+}
@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestCloneConfigPrepare_CloneMode(t *testing.T) {
+	// Test with empty
+	c := new(CloneConfig)
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	if c.CloneMode != CloneModeFull {
+		t.Fatalf("bad: %#v", c.CloneMode)
+	}
+
+	// Test with a good one
+	c = new(CloneConfig)
+	c.CloneMode = "linked"
+	errs = c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	if !c.IsLinked() {
+		t.Fatal("should be linked")
+	}
+
+	// Test with a bad one
+	c = new(CloneConfig)
+	c.CloneMode = "foo"
+	errs = c.Prepare(interpolate.NewContext())
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestCloneConfigPrepare_FlattenLinkedClone(t *testing.T) {
+	// Flattening a full clone makes no sense
+	c := new(CloneConfig)
+	c.FlattenLinkedClone = true
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	c = new(CloneConfig)
+	c.CloneMode = "linked"
+	c.FlattenLinkedClone = true
+	errs = c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// These are the capabilities of the Parallels Desktop versions which
+// determine the default settings applied to new VMs.
+const (
+	// Settings available in all the supported versions
+	CapabilityBase = "base"
+	// Sharing of the cloud storages, profile, mounts and applications (PD 10+)
+	CapabilitySharing = "sharing"
+	// Headless startup view (PD 11+)
+	CapabilityHeadless = "headless"
+)
+
+// DefaultSetting is a VM setting applied when the VM is created, so that it
+// doesn't interfere with the build.
+type DefaultSetting struct {
+	Option string
+	Value  string
+}
+
+// defaultSettings is the table of the default settings, by capability. When
+// the same option appears for several capabilities of a version, the last
+// one applied wins.
+var defaultSettings = map[string][]DefaultSetting{
+	CapabilityBase: {
+		{"--startup-view", "same"},
+		{"--on-shutdown", "close"},
+		{"--on-window-close", "keep-running"},
+		{"--auto-share-camera", "off"},
+		{"--smart-guard", "off"},
+	},
+	CapabilitySharing: {
+		{"--shared-cloud", "off"},
+		{"--shared-profile", "off"},
+		{"--smart-mount", "off"},
+		{"--sh-app-guest-to-host", "off"},
+		{"--sh-app-host-to-guest", "off"},
+	},
+	CapabilityHeadless: {
+		{"--startup-view", "headless"},
+	},
+}
+
+// The errors reported by prlctl for options it doesn't know, rather than for
+// invalid values of the options it knows
+var unknownOptionRe = regexp.MustCompile(`(?i)(unknown|unrecognized) option`)
+
+// DefaultSettingsConfig contains the configuration of the default settings
+// applied to new VMs.
+type DefaultSettingsConfig struct {
+	// Overrides the default settings applied to the VM when it is created.
+	// The keys are prlctl set options, with or without the leading dashes,
+	// e.g. `{ "startup-view" = "window" }`. An empty value disables the
+	// default setting, other options are applied in addition to the defaults.
+	// The build fails if an overridden option can't be applied, whereas the
+	// default options unknown to Parallels Desktop are skipped with a warning.
+	DefaultSettingsOverrides map[string]string `mapstructure:"default_settings_overrides" required:"false"`
+	// If true, no default settings are applied to the VM when it is created.
+	// Defaults to false.
+	SkipDefaultSettings bool `mapstructure:"skip_default_settings" required:"false"`
+}
+
+// Prepare normalizes the overridden option names.
+func (c *DefaultSettingsConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	overrides := make(map[string]string, len(c.DefaultSettingsOverrides))
+	for option, value := range c.DefaultSettingsOverrides {
+		name := strings.TrimLeft(option, "-")
+		if name == "" || strings.ContainsAny(name, " \t") {
+			errs = append(errs, fmt.Errorf("invalid option in default_settings_overrides: %q", option))
+			continue
+		}
+		overrides["--"+name] = value
+	}
+	c.DefaultSettingsOverrides = overrides
+
+	return errs
+}
+
+// resolveDefaultSettings returns the default settings for the given
+// capabilities, with the overrides applied.
+func resolveDefaultSettings(capabilities []string, overrides map[string]string) []DefaultSetting {
+	var options []string
+	values := make(map[string]string)
+	set := func(option, value string) {
+		if _, ok := values[option]; !ok {
+			options = append(options, option)
+		}
+		values[option] = value
+	}
+
+	for _, capability := range capabilities {
+		for _, setting := range defaultSettings[capability] {
+			set(setting.Option, setting.Value)
+		}
+	}
+	for _, option := range sortedKeys(overrides) {
+		set(option, overrides[option])
+	}
+
+	settings := make([]DefaultSetting, 0, len(options))
+	for _, option := range options {
+		if values[option] == "" {
+			continue
+		}
+		settings = append(settings, DefaultSetting{option, values[option]})
+	}
+	return settings
+}
+
+// applyDefaultSettings applies the default settings to the VM. The built-in
+// options unknown to the installed version of Parallels Desktop are reported
+// as warnings, while any error applying an overridden option fails.
+func applyDefaultSettings(d Driver, vmName string, capabilities []string, overrides map[string]string) ([]string, error) {
+	var warnings []string
+	for _, setting := range resolveDefaultSettings(capabilities, overrides) {
+		err := d.Prlctl("set", vmName, setting.Option, setting.Value)
+		if err == nil {
+			continue
+		}
+		if _, overridden := overrides[setting.Option]; overridden || !unknownOptionRe.MatchString(err.Error()) {
+			return warnings, err
+		}
+
+		log.Printf("Ignoring default setting %s: %s", setting.Option, err)
+		warnings = append(warnings, fmt.Sprintf("%s %s was not applied: %s", setting.Option, setting.Value, err))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestDefaultSettingsConfigPrepare(t *testing.T) {
+	c := new(DefaultSettingsConfig)
+	c.DefaultSettingsOverrides = map[string]string{
+		"startup-view":  "window",
+		"--smart-guard": "",
+		"-on-shutdown":  "quit",
+		"--bad option":  "on",
+		"--":            "on",
+	}
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) != 2 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	expected := map[string]string{
+		"--startup-view": "window",
+		"--smart-guard":  "",
+		"--on-shutdown":  "quit",
+	}
+	if !reflect.DeepEqual(c.DefaultSettingsOverrides, expected) {
+		t.Fatalf("bad: %#v", c.DefaultSettingsOverrides)
+	}
+}
+
+func TestResolveDefaultSettings(t *testing.T) {
+	settings := resolveDefaultSettings(
+		[]string{CapabilityBase, CapabilityHeadless},
+		map[string]string{
+			"--smart-guard": "",
+			"--on-shutdown": "quit",
+			"--isolate-vm":  "on",
+		},
+	)
+
+	expected := []DefaultSetting{
+		{"--startup-view", "headless"},
+		{"--on-shutdown", "quit"},
+		{"--on-window-close", "keep-running"},
+		{"--auto-share-camera", "off"},
+		{"--isolate-vm", "on"},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Fatalf("bad: %#v", settings)
+	}
+}
+
+func TestApplyDefaultSettings(t *testing.T) {
+	driver := new(DriverMock)
+	driver.PrlctlErrs = []error{
+		nil,
+		errors.New("prlctl error: Unknown option: --on-shutdown"),
+	}
+
+	warnings, err := applyDefaultSettings(driver, "foo", []string{CapabilityBase}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if len(driver.PrlctlCalls) != len(defaultSettings[CapabilityBase]) {
+		t.Fatalf("bad: %#v", driver.PrlctlCalls)
+	}
+	expected := []string{"set", "foo", "--startup-view", "same"}
+	if !reflect.DeepEqual(driver.PrlctlCalls[0], expected) {
+		t.Fatalf("bad: %#v", driver.PrlctlCalls[0])
+	}
+}
+
+func TestApplyDefaultSettings_error(t *testing.T) {
+	driver := new(DriverMock)
+	driver.PrlctlErrs = []error{errors.New("VM is locked")}
+
+	if _, err := applyDefaultSettings(driver, "foo", []string{CapabilityBase}, nil); err == nil {
+		t.Fatal("should error")
+	}
+	if len(driver.PrlctlCalls) != 1 {
+		t.Fatalf("bad: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestApplyDefaultSettings_invalidValue(t *testing.T) {
+	driver := new(DriverMock)
+	driver.PrlctlErrs = []error{errors.New("prlctl error: Invalid option value: --startup-view same")}
+
+	if _, err := applyDefaultSettings(driver, "foo", []string{CapabilityBase}, nil); err == nil {
+		t.Fatal("should error")
+	}
+	if len(driver.PrlctlCalls) != 1 {
+		t.Fatalf("bad: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestApplyDefaultSettings_override(t *testing.T) {
+	// The unknown options overridden by the user fail the build
+	driver := new(DriverMock)
+	driver.PrlctlErrs = []error{nil, errors.New("prlctl error: Unknown option: --on-shutdown")}
+	overrides := map[string]string{"--on-shutdown": "quit"}
+
+	if _, err := applyDefaultSettings(driver, "foo", []string{CapabilityBase}, overrides); err == nil {
+		t.Fatal("should error")
+	}
+	expected := []string{"set", "foo", "--on-shutdown", "quit"}
+	if len(driver.PrlctlCalls) != 2 || !reflect.DeepEqual(driver.PrlctlCalls[1], expected) {
+		t.Fatalf("bad: %#v", driver.PrlctlCalls)
+	}
+}
@@ -32,8 +32,34 @@ type Driver interface {
 	// Get path to the first virtual disk image
 	DiskPath(string) (string, error)
 
-	// Import a VM
-	Import(string, string, string, bool) error
+	// Get paths to all the virtual disk images
+	DiskPaths(string) ([]string, error)
+
+	// Merge the data of the parent VM into the virtual disk of a linked clone
+	FlattenDisk(string) error
+
+	// Import a VM, and return the ID of the snapshot of the source VM taken
+	// for a linked clone, if any
+	Import(string, string, string, bool, bool) (string, error)
+
+	// Clone a VM which is already registered, and return the ID of the
+	// snapshot of the source VM taken for a linked clone, if any
+	Clone(string, string, string, string, bool, bool) (string, error)
+
+	// Find a registered VM or template by its name or UUID
+	LookupVM(string) (*RegisteredVM, error)
+
+	// Get the current values of the VM settings changed by the builder
+	VMSettings(string) (map[string]string, error)
+
+	// Get the names of the snapshots of a VM, by snapshot ID
+	Snapshots(string) (map[string]string, error)
+
+	// Get the ID of a snapshot by its name or ID
+	SnapshotID(string, string) (string, error)
+
+	// Delete a snapshot of a registered VM, or of the VM at the given path
+	DeleteSnapshot(string, string) error
 
 	// Checks if the VM with the given name is running.
 	IsRunning(string) (bool, error)
@@ -61,8 +87,20 @@ type Driver interface {
 	// Send scancodes to the vm using the prltype python script.
 	SendKeyScanCodes(string, ...string) error
 
-	// Apply default configuration settings to the virtual machine
-	SetDefaultConfiguration(string) error
+	// Send the key events of a group of keys to the vm at once.
+	SendKeyEvents(string, []KeyEvent) error
+
+	// Move the mouse pointer of the vm to the coordinates, and press,
+	// release or click the button.
+	SendMouseEvent(vm string, x, y int, button, action string) error
+
+	// Checks that the Parallels Virtualization SDK, which sends the mouse
+	// events, is installed.
+	VerifySDK() error
+
+	// Apply default configuration settings to the virtual machine, with the
+	// given overrides. Returns the settings which could not be applied.
+	SetDefaultConfiguration(string, map[string]string) ([]string, error)
 
 	// Finds the MAC address of the NIC nic0
 	MAC(string) (string, error)
@@ -71,6 +109,31 @@ type Driver interface {
 	IPAddress(string, string) (string, error)
 }
 
+// RegisteredVM describes a VM or template registered in Parallels Desktop.
+type RegisteredVM struct {
+	ID       string
+	Name     string
+	State    string
+	Home     string
+	Template bool
+	GuestOS  string
+	CPUs     int
+	// Memory size in megabytes
+	Memory       int
+	MACAddresses []string
+	Disks        []RegisteredVMDisk
+}
+
+// RegisteredVMDisk describes a virtual disk of a registered VM.
+type RegisteredVMDisk struct {
+	Name  string
+	Image string
+	// The disk format, "expanded" or "plain"
+	Type string
+	// Virtual size in megabytes
+	Size int64
+}
+
 // NewDriver returns a new driver implementation for this version of Parallels
 // Desktop, or an error if the driver couldn't be initialized.
 func NewDriver() (Driver, error) {
@@ -178,7 +241,7 @@ func checkforPythonSDK(prlctlPath string) error {
 
 	if verErr != nil && prlctlCurrVersion.LessThan(v2) {
 		pyPath := os.Getenv("PYTHONPATH")
-		os.Setenv("PYTHONPATH", pyPath+":/Library/Frameworks/ParallelsVirtualizationSDK.framework/Versions/Current/Libraries/Python/3.7")
+		os.Setenv("PYTHONPATH", pyPath+":"+prlsdkPythonPath)
 		cmd := exec.Command("/usr/bin/python3", "-c", `import prlsdkapi`)
 		err = cmd.Run()
 		if err != nil {
@@ -13,6 +13,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -23,13 +24,6 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 )
 
-// Struct to format keyCodes in JSON
-type keyCodes struct {
-	KeyCode int    `json:"key"`
-	Event   string `json:"event"`
-	Delay   int    `json:"delay"`
-}
-
 // sending scancodes to VM via prlctl send-key-event CMD
 func (d *Parallels9Driver) sendJsonScancodes(vmName string, inputScanCodes []string) error {
 
@@ -41,35 +35,40 @@ func (d *Parallels9Driver) sendJsonScancodes(vmName string, inputScanCodes []str
 		fmt.Sscanf(hexStr, "%X", &val) // Convert hex string to uint
 		inputHexScanCodes = append(inputHexScanCodes, val)
 	}
-	keyCodeData := []keyCodes{}
+	keyEvents := []KeyEvent{}
 	for i := 0; i < len(inputHexScanCodes); i++ {
 		key1 := inputHexScanCodes[i]
 		if key1 == 224 {
 			key2 := inputHexScanCodes[i+1]
 			i = i + 1
 			if key2 < 128 {
-				keyCodeData = append(keyCodeData, keyCodes{KeyCode: getKeycodeFromScanCode([]uint{key1, key2}), Event: "press", Delay: delay})
+				keyEvents = append(keyEvents, KeyEvent{Key: getKeycodeFromScanCode([]uint{key1, key2}), Event: KeyEventPress, Delay: delay})
 			} else {
-				keyCodeData = append(keyCodeData, keyCodes{KeyCode: getKeycodeFromScanCode([]uint{key1, key2 - 128}), Event: "release", Delay: delay})
+				keyEvents = append(keyEvents, KeyEvent{Key: getKeycodeFromScanCode([]uint{key1, key2 - 128}), Event: KeyEventRelease, Delay: delay})
 			}
 		} else if key1 < 128 {
-			keyCodeData = append(keyCodeData, keyCodes{KeyCode: getKeycodeFromScanCode([]uint{key1}), Event: "press", Delay: delay})
+			keyEvents = append(keyEvents, KeyEvent{Key: getKeycodeFromScanCode([]uint{key1}), Event: KeyEventPress, Delay: delay})
 		} else {
-			keyCodeData = append(keyCodeData, keyCodes{KeyCode: getKeycodeFromScanCode([]uint{key1 - 128}), Event: "release", Delay: delay})
+			keyEvents = append(keyEvents, KeyEvent{Key: getKeycodeFromScanCode([]uint{key1 - 128}), Event: KeyEventRelease, Delay: delay})
 		}
 	}
-	jsonFormat, err := json.MarshalIndent(keyCodeData, "", "\t")
+	return d.sendKeyEvents(vmName, keyEvents)
+}
+
+// sending key events to VM via prlctl send-key-event CMD
+func (d *Parallels9Driver) sendKeyEvents(vmName string, keyEvents []KeyEvent) error {
+	jsonFormat, err := json.MarshalIndent(keyEvents, "", "\t")
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	log.Printf("complete scancode data in JSON format %s", jsonFormat)
+	log.Printf("complete key event data in JSON format %s", jsonFormat)
 
 	var stdout, stderr bytes.Buffer
 	cmd := exec.Command(d.PrlctlPath, "send-key-event", vmName, "-j")
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	cmd.Stdin = strings.NewReader(string(jsonFormat))
+	cmd.Stdin = bytes.NewReader(jsonFormat)
 	err = cmd.Run()
 	stdoutString := strings.TrimSpace(stdout.String())
 	stderrString := strings.TrimSpace(stderr.String())
@@ -99,40 +98,333 @@ type Parallels9Driver struct {
 }
 
 // Import creates a clone of the source VM and reassigns the MAC address if needed.
-func (d *Parallels9Driver) Import(name, srcPath, dstDir string, reassignMAC bool) error {
+// If linked is true, a linked clone based on a new snapshot of the source VM
+// is created instead of a full copy, and the ID of the snapshot is returned.
+func (d *Parallels9Driver) Import(name, srcPath, dstDir string, reassignMAC, linked bool) (string, error) {
 	err := d.Prlctl("register", srcPath, "--preserve-uuid")
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	srcID, err := getVMID(srcPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	srcMAC := "auto"
 	if !reassignMAC {
 		srcMAC, err = getFirstMACAddress(srcPath)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
-	err = d.Prlctl("clone", srcID, "--name", name, "--dst", dstDir)
+	snapshotID, err := d.clone(name, srcID, dstDir, "", linked)
 	if err != nil {
-		return err
+		return snapshotID, err
 	}
 
 	err = d.Prlctl("unregister", srcID)
 	if err != nil {
-		return err
+		return snapshotID, err
 	}
 
 	err = d.Prlctl("set", name, "--device-set", "net0", "--mac", srcMAC)
 	if err != nil {
-		return err
+		return snapshotID, err
 	}
-	return nil
+	return snapshotID, nil
+}
+
+// Clone creates a clone of a VM which is already registered in Parallels
+// Desktop. Unlike Import, the source VM is left registered. If linked is
+// true, the clone is based on the given snapshot, or on a new one if
+// snapshotID is empty, whose ID is returned.
+func (d *Parallels9Driver) Clone(name, srcID, dstDir, snapshotID string, reassignMAC, linked bool) (string, error) {
+	var err error
+	srcMAC := "auto"
+	if !reassignMAC {
+		srcMAC, err = d.MAC(srcID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	newSnapshotID, err := d.clone(name, srcID, dstDir, snapshotID, linked)
+	if err != nil {
+		return newSnapshotID, err
+	}
+
+	return newSnapshotID, d.Prlctl("set", name, "--device-set", "net0", "--mac", srcMAC)
+}
+
+// clone clones the VM, and returns the ID of the snapshot it took for a
+// linked clone, if snapshotID is empty.
+func (d *Parallels9Driver) clone(name, srcID, dstDir, snapshotID string, linked bool) (string, error) {
+	command := []string{"clone", srcID, "--name", name, "--dst", dstDir}
+	newSnapshotID := ""
+	if linked {
+		if snapshotID == "" {
+			var err error
+			newSnapshotID, err = d.createSnapshot(srcID, "packer-"+name)
+			if err != nil {
+				return "", err
+			}
+			snapshotID = newSnapshotID
+		}
+		command = append(command, "--linked", "--id", snapshotID)
+	}
+
+	return newSnapshotID, d.Prlctl(command...)
+}
+
+// LookupVM finds a registered VM or template by its name or UUID.
+func (d *Parallels9Driver) LookupVM(nameOrID string) (*RegisteredVM, error) {
+	out, err := d.PrlctlGet("list", "--info", "--json", nameOrID)
+	if err != nil {
+		return nil, fmt.Errorf("VM %q is not registered in Parallels Desktop: %s", nameOrID, err)
+	}
+
+	vm, err := parseVMInfo(out)
+	if err != nil {
+		return nil, fmt.Errorf("VM %q: %s", nameOrID, err)
+	}
+	return vm, nil
+}
+
+// parseVMInfo parses the output of "prlctl list --info --json" for a single VM.
+func parseVMInfo(out string) (*RegisteredVM, error) {
+	var vms []struct {
+		ID       string                     `json:"ID"`
+		Name     string                     `json:"Name"`
+		State    string                     `json:"State"`
+		Home     string                     `json:"Home"`
+		Template string                     `json:"Template"`
+		OS       string                     `json:"OS"`
+		Hardware map[string]json.RawMessage `json:"Hardware"`
+	}
+	if err := json.Unmarshal([]byte(out), &vms); err != nil {
+		return nil, fmt.Errorf("Could not parse the VM information: %s", err)
+	}
+	if len(vms) != 1 {
+		return nil, fmt.Errorf("VM is not registered in Parallels Desktop")
+	}
+
+	info := vms[0]
+	vm := &RegisteredVM{
+		ID:       info.ID,
+		Name:     info.Name,
+		State:    info.State,
+		Home:     strings.TrimSuffix(info.Home, "/"),
+		Template: info.Template == "yes",
+		GuestOS:  info.OS,
+	}
+
+	// Devices are listed as "hdd0", "net0"... and have to be sorted to keep
+	// the result stable.
+	devices := make([]string, 0, len(info.Hardware))
+	for name := range info.Hardware {
+		devices = append(devices, name)
+	}
+	sort.Strings(devices)
+
+	for _, name := range devices {
+		var device struct {
+			CPUs  int    `json:"cpus"`
+			Size  string `json:"size"`
+			Image string `json:"image"`
+			Type  string `json:"type"`
+			MAC   string `json:"mac"`
+		}
+		if err := json.Unmarshal(info.Hardware[name], &device); err != nil {
+			// Not all the devices are objects, e.g. the video device on older versions
+			continue
+		}
+
+		switch {
+		case name == "cpu":
+			vm.CPUs = device.CPUs
+		case name == "memory":
+			vm.Memory = int(parseSizeMB(device.Size))
+		case strings.HasPrefix(name, "hdd"):
+			vm.Disks = append(vm.Disks, RegisteredVMDisk{
+				Name:  name,
+				Image: strings.TrimSuffix(device.Image, "/"),
+				Type:  device.Type,
+				Size:  parseSizeMB(device.Size),
+			})
+		case strings.HasPrefix(name, "net") && device.MAC != "":
+			vm.MACAddresses = append(vm.MACAddresses, device.MAC)
+		}
+	}
+
+	return vm, nil
+}
+
+// parseSizeMB parses sizes like "2048Mb" or "64Gb" reported by prlctl and
+// returns them in megabytes.
+func parseSizeMB(size string) int64 {
+	re := regexp.MustCompile(`^(\d+)\s*([KMGT]?)[Bb]?$`)
+	match := re.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0
+	}
+
+	value, _ := strconv.ParseInt(match[1], 10, 64)
+	switch match[2] {
+	case "K":
+		return value / 1024
+	case "G":
+		return value * 1024
+	case "T":
+		return value * 1024 * 1024
+	}
+	return value
+}
+
+// VMSettings returns the current values of the VM settings changed by the
+// builder, by prlctl option name.
+func (d *Parallels9Driver) VMSettings(vmName string) (map[string]string, error) {
+	out, err := d.PrlctlGet("list", "--info", "--json", vmName)
+	if err != nil {
+		return nil, err
+	}
+	return parseVMSettings(out)
+}
+
+// The location of the settings in the output of "prlctl list --info --json",
+// by prlctl option name
+var vmSettingKeys = map[string][2]string{
+	"--startup-view":             {"Startup and Shutdown", "Startup view"},
+	"--on-shutdown":              {"Startup and Shutdown", "On shutdown"},
+	"--on-window-close":          {"Startup and Shutdown", "On window close"},
+	"--auto-share-camera":        {"USB and Bluetooth", "Automatic sharing cameras"},
+	"--smart-guard":              {"SmartGuard", "enabled"},
+	"--shared-cloud":             {"Miscellaneous Sharing", "Shared cloud"},
+	"--shared-profile":           {"Shared Profile", "enabled"},
+	"--smart-mount":              {"SmartMount", "enabled"},
+	"--sh-app-guest-to-host":     {"Shared Applications", "Guest-to-host apps sharing"},
+	"--sh-app-host-to-guest":     {"Shared Applications", "Host-to-guest apps sharing"},
+	"--show-guest-notifications": {"Shared Applications", "Show guest notifications"},
+}
+
+// The values displayed by prlctl which differ from the ones it accepts
+var vmSettingValues = map[string]string{
+	"true":             "on",
+	"false":            "off",
+	"close window":     "close",
+	"keep window":      "window",
+	"keep running":     "keep-running",
+	"keep-window-open": "window",
+}
+
+// parseVMSettings extracts the VM settings from the output of
+// "prlctl list --info --json". The settings which are not reported are
+// left out.
+func parseVMSettings(out string) (map[string]string, error) {
+	var vms []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out), &vms); err != nil {
+		return nil, fmt.Errorf("Could not parse the VM information: %s", err)
+	}
+	if len(vms) != 1 {
+		return nil, fmt.Errorf("VM is not registered in Parallels Desktop")
+	}
+
+	settings := make(map[string]string)
+	for option, key := range vmSettingKeys {
+		var section map[string]interface{}
+		if err := json.Unmarshal(vms[0][key[0]], &section); err != nil {
+			continue
+		}
+
+		value, ok := section[key[1]]
+		if !ok {
+			continue
+		}
+
+		v := strings.ToLower(fmt.Sprint(value))
+		if replacement, ok := vmSettingValues[v]; ok {
+			v = replacement
+		}
+		settings[option] = v
+	}
+
+	return settings, nil
+}
+
+// Snapshots returns the names of the snapshots of the VM, by snapshot ID.
+func (d *Parallels9Driver) Snapshots(vmName string) (map[string]string, error) {
+	out, err := d.PrlctlGet("snapshot-list", vmName, "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots map[string]struct {
+		Name string `json:"name"`
+	}
+	if out != "" {
+		if err := json.Unmarshal([]byte(out), &snapshots); err != nil {
+			return nil, fmt.Errorf("Could not parse the snapshot list: %s", err)
+		}
+	}
+
+	result := make(map[string]string, len(snapshots))
+	for id, info := range snapshots {
+		result[id] = info.Name
+	}
+	return result, nil
+}
+
+// SnapshotID returns the ID of the snapshot of the VM with the given name or ID.
+func (d *Parallels9Driver) SnapshotID(vmName, snapshot string) (string, error) {
+	snapshots, err := d.Snapshots(vmName)
+	if err != nil {
+		return "", err
+	}
+
+	for id, name := range snapshots {
+		if id == snapshot || strings.Trim(id, "{}") == snapshot || name == snapshot {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("Snapshot %q not found in VM %q", snapshot, vmName)
+}
+
+// DeleteSnapshot deletes the snapshot of the VM, given by its name or ID, or
+// by its path if it isn't registered. The VM is then registered for the time
+// of the deletion.
+func (d *Parallels9Driver) DeleteSnapshot(vm, snapshotID string) error {
+	if info, err := os.Stat(vm); err == nil && info.IsDir() {
+		vmID, err := getVMID(vm)
+		if err != nil {
+			return err
+		}
+		if err := d.Prlctl("register", vm, "--preserve-uuid"); err != nil {
+			return err
+		}
+		defer d.Prlctl("unregister", vmID)
+		vm = vmID
+	}
+
+	return d.Prlctl("snapshot-delete", vm, "--id", snapshotID)
+}
+
+// createSnapshot takes a snapshot of the VM and returns its ID.
+func (d *Parallels9Driver) createSnapshot(vmName, snapshotName string) (string, error) {
+	out, err := d.PrlctlGet("snapshot", vmName, "--name", snapshotName)
+	if err != nil {
+		return "", err
+	}
+
+	snapshotRe := regexp.MustCompile(`\{[0-9a-fA-F-]+\}`)
+	snapshotID := snapshotRe.FindString(out)
+	if snapshotID == "" {
+		return "", fmt.Errorf(
+			"Could not determine snapshot ID in the output:\n%s", out)
+	}
+
+	return snapshotID, nil
 }
 
 func getVMID(path string) (string, error) {
@@ -216,6 +508,25 @@ func (d *Parallels9Driver) CompactDisk(diskPath string) error {
 	return nil
 }
 
+// FlattenDisk merges the data of the parent VM into the virtual disk of a
+// linked clone, so that the disk no longer depends on its parent.
+func (d *Parallels9Driver) FlattenDisk(diskPath string) error {
+	prlDiskToolPath, err := exec.LookPath("prl_disk_tool")
+	if err != nil {
+		return err
+	}
+
+	command := []string{
+		"merge", "--external",
+		"--hdd", diskPath,
+	}
+	if out, err := exec.Command(prlDiskToolPath, command...).CombinedOutput(); err != nil {
+		return fmt.Errorf("prl_disk_tool error: %s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
 // DeviceAddCDROM adds a virtual CDROM device and attaches the specified image.
 func (d *Parallels9Driver) DeviceAddCDROM(name string, image string) (string, error) {
 	command := []string{
@@ -259,6 +570,27 @@ func (d *Parallels9Driver) DiskPath(name string) (string, error) {
 	return HDDPath, nil
 }
 
+// DiskPaths returns full paths to all the virtual disk drives.
+func (d *Parallels9Driver) DiskPaths(name string) ([]string, error) {
+	out, err := exec.Command(d.PrlctlPath, "list", "-i", name).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	HDDRe := regexp.MustCompile("hdd[0-9]+.* image='(.*)' type=*")
+	matches := HDDRe.FindAllStringSubmatch(string(out), -1)
+	if matches == nil {
+		return nil, fmt.Errorf(
+			"Could not determine hdd image paths in the output:\n%s", string(out))
+	}
+
+	var paths []string
+	for _, match := range matches {
+		paths = append(paths, match[1])
+	}
+	return paths, nil
+}
+
 // IsRunning determines whether the VM is running or not.
 func (d *Parallels9Driver) IsRunning(name string) (bool, error) {
 	var stdout bytes.Buffer
@@ -358,55 +690,118 @@ func (d *Parallels9Driver) Version() (string, error) {
 // It is performed using "Prltype" script (refer to "prltype.go") if version is  < 19.0.0.
 // scancodes are sent by using prlctl CMD if version is  >= 19.0.0
 func (d *Parallels9Driver) SendKeyScanCodes(vmName string, codes ...string) error {
-	var stdout, stderr bytes.Buffer
-	var err error
-
 	if len(codes) == 0 {
 		log.Printf("No scan codes to send")
 		return nil
 	}
 
-	prlctlCurrVersionStr, verErr := d.Version()
-	if verErr != nil {
+	supported, err := d.supportsKeyEvents()
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return d.prltype(vmName, codes)
+	}
+	return d.sendJsonScancodes(vmName, codes)
+}
+
+// SendKeyEvents sends the key events to the VM at once, by using prlctl CMD
+// if version is >= 19.0.0. Older versions don't support it, the events are
+// converted to scancodes and sent using "Prltype" script instead.
+func (d *Parallels9Driver) SendKeyEvents(vmName string, events []KeyEvent) error {
+	if len(events) == 0 {
+		log.Printf("No key events to send")
+		return nil
+	}
+
+	supported, err := d.supportsKeyEvents()
+	if err != nil {
 		return err
 	}
+	if !supported {
+		return d.prltype(vmName, keyEventScancodes(events))
+	}
+	return d.sendKeyEvents(vmName, events)
+}
+
+// supportsKeyEvents reports whether "prlctl send-key-event -j" is available.
+func (d *Parallels9Driver) supportsKeyEvents() (bool, error) {
+	prlctlCurrVersionStr, err := d.Version()
+	if err != nil {
+		return false, err
+	}
 	prlctlCurrVersion, _ := version.NewVersion(prlctlCurrVersionStr)
 	v2, _ := version.NewVersion("19.0.0")
+	return !prlctlCurrVersion.LessThan(v2), nil
+}
 
-	if prlctlCurrVersion.LessThan(v2) {
+// prltype sends the scancodes to the VM using "Prltype" script.
+func (d *Parallels9Driver) prltype(vmName string, codes []string) error {
+	return runSDKScript("prltype", Prltype, prepend(vmName, codes))
+}
 
-		f, err := tmp.File("prltype")
-		if err != nil {
-			return err
-		}
-		defer os.Remove(f.Name())
+// SendMouseEvent moves the mouse pointer of the VM to the specified
+// coordinates and performs the action with the button, using "Prlmouse"
+// script (refer to "prlmouse.go").
+func (d *Parallels9Driver) SendMouseEvent(vmName string, x, y int, button, action string) error {
+	mask, ok := mouseButtonMasks[button]
+	if !ok {
+		return fmt.Errorf("unknown mouse button: %s", button)
+	}
+	switch action {
+	case MouseActionMove, MouseActionPress, MouseActionRelease, MouseActionClick:
+	default:
+		return fmt.Errorf("unknown mouse action: %s", action)
+	}
 
-		script := []byte(Prltype)
-		_, err = f.Write(script)
-		if err != nil {
-			return err
-		}
+	log.Printf("Sending mouse %s of button '%s' at %d,%d", action, button, x, y)
+	return runSDKScript("prlmouse", Prlmouse, []string{
+		vmName, strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(mask), action,
+	})
+}
 
-		args := prepend(vmName, codes)
-		args = prepend(f.Name(), args)
-		cmd := exec.Command("/usr/bin/python3", args...)
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-		err = cmd.Run()
+// VerifySDK checks that the prlsdkapi Python module of the Parallels
+// Virtualization SDK can be imported.
+func (d *Parallels9Driver) VerifySDK() error {
+	out, err := prlsdkPython("-c", "import prlsdkapi").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Parallels Virtualization SDK is not installed, its prlsdkapi Python module is required: %s",
+			strings.TrimSpace(string(out)))
+	}
+	return nil
+}
 
-		stdoutString := strings.TrimSpace(stdout.String())
-		stderrString := strings.TrimSpace(stderr.String())
+// runSDKScript runs the Python script, built on the Parallels Virtualization
+// SDK, with the arguments.
+func runSDKScript(name, script string, args []string) error {
+	var stdout, stderr bytes.Buffer
 
-		if _, ok := err.(*exec.ExitError); ok {
-			err = fmt.Errorf("prltype error: %s", stderrString)
-			return err
-		}
+	f, err := tmp.File(name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
 
-		log.Printf("stdout: %s", stdoutString)
-		log.Printf("stderr: %s", stderrString)
-	} else {
-		err = d.sendJsonScancodes(vmName, codes)
+	_, err = f.Write([]byte(script))
+	if err != nil {
+		return err
 	}
+
+	cmd := prlsdkPython(prepend(f.Name(), args)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("%s error: %s", name, stderrString)
+		return err
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
 	return err
 }
 
@@ -420,21 +815,9 @@ func prepend(head string, tail []string) []string {
 }
 
 // SetDefaultConfiguration applies pre-defined default settings to the VM config.
-func (d *Parallels9Driver) SetDefaultConfiguration(vmName string) error {
-	commands := make([][]string, 5)
-	commands[0] = []string{"set", vmName, "--startup-view", "same"}
-	commands[1] = []string{"set", vmName, "--on-shutdown", "close"}
-	commands[2] = []string{"set", vmName, "--on-window-close", "keep-running"}
-	commands[3] = []string{"set", vmName, "--auto-share-camera", "off"}
-	commands[4] = []string{"set", vmName, "--smart-guard", "off"}
-
-	for _, command := range commands {
-		err := d.Prlctl(command...)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+// Parallels Desktop 9 doesn't support the sharing options.
+func (d *Parallels9Driver) SetDefaultConfiguration(vmName string, overrides map[string]string) ([]string, error) {
+	return applyDefaultSettings(d, vmName, []string{CapabilityBase}, overrides)
 }
 
 // MAC returns the MAC address of the VM's first network interface.
@@ -88,3 +88,114 @@ func TestXMLParseConfig(t *testing.T) {
 		t.Fatalf("Expected %q, got %q", "20", result)
 	}
 }
+
+func TestParseVMInfo(t *testing.T) {
+	out := `[
+  {
+    "ID": "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+    "Name": "packer-ubuntu",
+    "State": "stopped",
+    "Home": "/Users/packer/output/packer-ubuntu.pvm/",
+    "Template": "no",
+    "OS": "ubuntu",
+    "Hardware": {
+      "cpu": {"cpus": 2, "VT-x": true, "mode": "64"},
+      "memory": {"size": "2048Mb", "auto": "off"},
+      "video": {"adapter-type": "vga", "size": "0Mb"},
+      "hdd0": {"enabled": true, "port": "sata:0", "image": "/Users/packer/output/packer-ubuntu.pvm/harddisk.hdd", "type": "expanded", "size": "65536Mb"},
+      "net1": {"enabled": true, "type": "host", "mac": "001C42AABBCD", "card": "virtio"},
+      "net0": {"enabled": true, "type": "shared", "mac": "001C42AABBCC", "card": "virtio"}
+    }
+  }
+]`
+
+	vm, err := parseVMInfo(out)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if vm.ID != "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}" || vm.Name != "packer-ubuntu" {
+		t.Fatalf("bad: %#v", vm)
+	}
+	if vm.Home != "/Users/packer/output/packer-ubuntu.pvm" {
+		t.Fatalf("bad home: %s", vm.Home)
+	}
+	if vm.GuestOS != "ubuntu" || vm.CPUs != 2 || vm.Memory != 2048 {
+		t.Fatalf("bad hardware: %#v", vm)
+	}
+	if len(vm.MACAddresses) != 2 || vm.MACAddresses[0] != "001C42AABBCC" {
+		t.Fatalf("bad MAC addresses: %#v", vm.MACAddresses)
+	}
+	if len(vm.Disks) != 1 || vm.Disks[0].Size != 65536 || vm.Disks[0].Type != "expanded" {
+		t.Fatalf("bad disks: %#v", vm.Disks)
+	}
+
+	if _, err := parseVMInfo("[]"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestParseSizeMB(t *testing.T) {
+	cases := map[string]int64{
+		"2048Mb": 2048,
+		"64Gb":   65536,
+		"1024":   1024,
+		"2048Kb": 2,
+		"auto":   0,
+	}
+
+	for size, expected := range cases {
+		if result := parseSizeMB(size); result != expected {
+			t.Errorf("%s: expected %d, got %d", size, expected, result)
+		}
+	}
+}
+
+func TestParseVMSettings(t *testing.T) {
+	out := `[
+  {
+    "ID": "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+    "Startup and Shutdown": {
+      "Autostart": "off",
+      "Startup view": "window",
+      "On shutdown": "close window",
+      "On window close": "keep running"
+    },
+    "SmartGuard": {"enabled": false},
+    "Shared Profile": {"enabled": true}
+  }
+]`
+
+	settings, err := parseVMSettings(out)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"--startup-view":    "window",
+		"--on-shutdown":     "close",
+		"--on-window-close": "keep-running",
+		"--smart-guard":     "off",
+		"--shared-profile":  "on",
+	}
+	if len(settings) != len(expected) {
+		t.Fatalf("bad: %#v", settings)
+	}
+	for option, value := range expected {
+		if settings[option] != value {
+			t.Fatalf("bad %s: %q", option, settings[option])
+		}
+	}
+}
+
+func TestParseVMSettings_coherence(t *testing.T) {
+	out := `[{"Startup and Shutdown": {"Startup view": "Coherence"}}]`
+
+	settings, err := parseVMSettings(out)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if settings["--startup-view"] != "coherence" {
+		t.Fatalf("bad: %#v", settings)
+	}
+}
@@ -3,7 +3,10 @@
 
 package common
 
-import "sync"
+import (
+	"strconv"
+	"sync"
+)
 
 type DriverMock struct {
 	sync.Mutex
@@ -23,11 +26,54 @@ type DriverMock struct {
 	DiskPathResult string
 	DiskPathErr    error
 
+	DiskPathsCalled bool
+	DiskPathsName   string
+	DiskPathsResult []string
+	DiskPathsErr    error
+
+	FlattenDiskCalled bool
+	FlattenDiskPath   string
+	FlattenDiskPaths  []string
+	FlattenDiskErr    error
+
 	ImportCalled  bool
 	ImportName    string
 	ImportSrcPath string
 	ImportDstPath string
+	ImportLinked  bool
 	ImportErr     error
+	// The snapshot taken by Import and Clone
+	CreatedSnapshotID string
+
+	CloneCalled     bool
+	CloneName       string
+	CloneSrcID      string
+	CloneDstPath    string
+	CloneSnapshotID string
+	CloneLinked     bool
+	CloneErr        error
+
+	LookupVMName   string
+	LookupVMResult *RegisteredVM
+	LookupVMErr    error
+
+	VMSettingsName   string
+	VMSettingsResult map[string]string
+	VMSettingsErr    error
+
+	SnapshotsName   string
+	SnapshotsResult map[string]string
+	SnapshotsErr    error
+
+	SnapshotIDVM       string
+	SnapshotIDSnapshot string
+	SnapshotIDResult   string
+	SnapshotIDErr      error
+
+	DeleteSnapshotCalled bool
+	DeleteSnapshotVM     string
+	DeleteSnapshotID     string
+	DeleteSnapshotErr    error
 
 	IsRunningName   string
 	IsRunningReturn bool
@@ -49,8 +95,19 @@ type DriverMock struct {
 	SendKeyScanCodesCalls [][]string
 	SendKeyScanCodesErrs  []error
 
-	SetDefaultConfigurationCalled bool
-	SetDefaultConfigurationError  error
+	SendKeyEventsCalls [][]KeyEvent
+	SendKeyEventsErrs  []error
+
+	SendMouseEventCalls [][]string
+	SendMouseEventErr   error
+
+	VerifySDKCalled bool
+	VerifySDKErr    error
+
+	SetDefaultConfigurationCalled    bool
+	SetDefaultConfigurationOverrides map[string]string
+	SetDefaultConfigurationWarnings  []string
+	SetDefaultConfigurationError     error
 
 	ToolsISOPathCalled bool
 	ToolsISOPathFlavor string
@@ -87,12 +144,64 @@ func (d *DriverMock) DiskPath(name string) (string, error) {
 	return d.DiskPathResult, d.DiskPathErr
 }
 
-func (d *DriverMock) Import(name, srcPath, dstPath string, reassignMAC bool) error {
+func (d *DriverMock) DiskPaths(name string) ([]string, error) {
+	d.DiskPathsCalled = true
+	d.DiskPathsName = name
+	return d.DiskPathsResult, d.DiskPathsErr
+}
+
+func (d *DriverMock) FlattenDisk(path string) error {
+	d.FlattenDiskCalled = true
+	d.FlattenDiskPath = path
+	d.FlattenDiskPaths = append(d.FlattenDiskPaths, path)
+	return d.FlattenDiskErr
+}
+
+func (d *DriverMock) Import(name, srcPath, dstPath string, reassignMAC, linked bool) (string, error) {
 	d.ImportCalled = true
 	d.ImportName = name
 	d.ImportSrcPath = srcPath
 	d.ImportDstPath = dstPath
-	return d.ImportErr
+	d.ImportLinked = linked
+	return d.CreatedSnapshotID, d.ImportErr
+}
+
+func (d *DriverMock) Clone(name, srcID, dstPath, snapshotID string, reassignMAC, linked bool) (string, error) {
+	d.CloneCalled = true
+	d.CloneName = name
+	d.CloneSrcID = srcID
+	d.CloneDstPath = dstPath
+	d.CloneSnapshotID = snapshotID
+	d.CloneLinked = linked
+	return d.CreatedSnapshotID, d.CloneErr
+}
+
+func (d *DriverMock) LookupVM(name string) (*RegisteredVM, error) {
+	d.LookupVMName = name
+	return d.LookupVMResult, d.LookupVMErr
+}
+
+func (d *DriverMock) VMSettings(vmName string) (map[string]string, error) {
+	d.VMSettingsName = vmName
+	return d.VMSettingsResult, d.VMSettingsErr
+}
+
+func (d *DriverMock) Snapshots(vmName string) (map[string]string, error) {
+	d.SnapshotsName = vmName
+	return d.SnapshotsResult, d.SnapshotsErr
+}
+
+func (d *DriverMock) SnapshotID(vmName, snapshot string) (string, error) {
+	d.SnapshotIDVM = vmName
+	d.SnapshotIDSnapshot = snapshot
+	return d.SnapshotIDResult, d.SnapshotIDErr
+}
+
+func (d *DriverMock) DeleteSnapshot(vm, snapshotID string) error {
+	d.DeleteSnapshotCalled = true
+	d.DeleteSnapshotVM = vm
+	d.DeleteSnapshotID = snapshotID
+	return d.DeleteSnapshotErr
 }
 
 func (d *DriverMock) IsRunning(name string) (bool, error) {
@@ -136,9 +245,30 @@ func (d *DriverMock) SendKeyScanCodes(name string, scancodes ...string) error {
 	return nil
 }
 
-func (d *DriverMock) SetDefaultConfiguration(name string) error {
+func (d *DriverMock) SendKeyEvents(name string, events []KeyEvent) error {
+	d.SendKeyEventsCalls = append(d.SendKeyEventsCalls, events)
+
+	if len(d.SendKeyEventsErrs) >= len(d.SendKeyEventsCalls) {
+		return d.SendKeyEventsErrs[len(d.SendKeyEventsCalls)-1]
+	}
+	return nil
+}
+
+func (d *DriverMock) SendMouseEvent(name string, x, y int, button, action string) error {
+	d.SendMouseEventCalls = append(d.SendMouseEventCalls,
+		[]string{name, strconv.Itoa(x), strconv.Itoa(y), button, action})
+	return d.SendMouseEventErr
+}
+
+func (d *DriverMock) VerifySDK() error {
+	d.VerifySDKCalled = true
+	return d.VerifySDKErr
+}
+
+func (d *DriverMock) SetDefaultConfiguration(name string, overrides map[string]string) ([]string, error) {
 	d.SetDefaultConfigurationCalled = true
-	return d.SetDefaultConfigurationError
+	d.SetDefaultConfigurationOverrides = overrides
+	return d.SetDefaultConfigurationWarnings, d.SetDefaultConfigurationError
 }
 
 func (d *DriverMock) MAC(name string) (string, error) {
@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type FinalVMConfig
+
+package common
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// FinalVMConfig contains the settings applied to the VM at the end of the
+// build, once it is shut down. The settings forced during the build (the
+// headless startup view, the disabled sharing...) are then replaced by the
+// ones the users of the VM should get. Besides the options below,
+// startup_view and on_window_close can be set the same way as in the VM
+// configuration. Coherence is a startup view: `startup_view = "coherence"`
+// starts the VM in Coherence, and a VM starting in Coherence before the
+// build does again with restore_original.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	final_vm_config {
+//	  restore_original = true
+//	  startup_view     = "window"
+//	  shared_profile   = true
+//	}
+//
+// ```
+type FinalVMConfig struct {
+	VMConfig `mapstructure:",squash"`
+	// If true, the settings of the VM are restored to the values they had
+	// before the build changed them. They are captured right after the VM is
+	// created or imported, before the default build settings are applied.
+	// The other options of this block take precedence over the restored
+	// values.
+	RestoreOriginal bool `mapstructure:"restore_original" required:"false"`
+	// The action taken when the guest OS shuts down. Possible values are:
+	// window, close, quit.
+	OnShutdown string `mapstructure:"on_shutdown" required:"false"`
+	// Whether the host camera is shared with the VM automatically.
+	AutoShareCamera config.Trilean `mapstructure:"auto_share_camera" required:"false"`
+	// Whether SmartGuard automatic snapshots are enabled.
+	SmartGuard config.Trilean `mapstructure:"smart_guard" required:"false"`
+	// Whether the cloud storages of the host are shared with the VM.
+	SharedCloud config.Trilean `mapstructure:"shared_cloud" required:"false"`
+	// Whether the host user profile folders are shared with the VM.
+	SharedProfile config.Trilean `mapstructure:"shared_profile" required:"false"`
+	// Whether removable drives and network shares are mounted in the VM.
+	SmartMount config.Trilean `mapstructure:"smart_mount" required:"false"`
+	// Whether the guest applications can be opened from the host.
+	ShareGuestApps config.Trilean `mapstructure:"share_guest_apps" required:"false"`
+	// Whether the host applications can be opened from the guest.
+	ShareHostApps config.Trilean `mapstructure:"share_host_apps" required:"false"`
+	// Whether the notifications of the guest applications are shown on the
+	// host, as in Coherence mode.
+	ShowGuestNotifications config.Trilean `mapstructure:"show_guest_notifications" required:"false"`
+}
+
+// Prepare validates the final VM settings.
+func (c *FinalVMConfig) Prepare(ctx *interpolate.Context) []error {
+	errs := c.VMConfig.Prepare(ctx)
+
+	var validOnShutdown = []string{"", "window", "close", "quit"}
+	if !slices.Contains(validOnShutdown, c.OnShutdown) {
+		errs = append(errs,
+			fmt.Errorf("invalid value for on_shutdown: %s. Allowed values are : %v", c.OnShutdown, validOnShutdown))
+	}
+
+	return errs
+}
+
+// Settings returns the prlctl options to set, by option name.
+func (c *FinalVMConfig) Settings() map[string]string {
+	settings := make(map[string]string)
+
+	values := map[string]string{
+		"--startup-view":    c.StartupView,
+		"--on-window-close": c.OnWindowClose,
+		"--on-shutdown":     c.OnShutdown,
+	}
+	for option, value := range values {
+		if value != "" {
+			settings[option] = value
+		}
+	}
+
+	switches := map[string]config.Trilean{
+		"--auto-share-camera":        c.AutoShareCamera,
+		"--smart-guard":              c.SmartGuard,
+		"--shared-cloud":             c.SharedCloud,
+		"--shared-profile":           c.SharedProfile,
+		"--smart-mount":              c.SmartMount,
+		"--sh-app-guest-to-host":     c.ShareGuestApps,
+		"--sh-app-host-to-guest":     c.ShareHostApps,
+		"--show-guest-notifications": c.ShowGuestNotifications,
+	}
+	for option, value := range switches {
+		if value.True() {
+			settings[option] = "on"
+		} else if value.False() {
+			settings[option] = "off"
+		}
+	}
+
+	return settings
+}
+
+// IsEmpty reports whether there is nothing to apply.
+func (c *FinalVMConfig) IsEmpty() bool {
+	return !c.RestoreOriginal && len(c.Settings()) == 0
+}
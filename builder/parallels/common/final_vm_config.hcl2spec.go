@@ -0,0 +1,53 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatFinalVMConfig is an auto-generated flat version of FinalVMConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatFinalVMConfig struct {
+	StartupView            *string `mapstructure:"startup_view" required:"false" cty:"startup_view" hcl:"startup_view"`
+	OnWindowClose          *string `mapstructure:"on_window_close" required:"false" cty:"on_window_close" hcl:"on_window_close"`
+	RestoreOriginal        *bool   `mapstructure:"restore_original" required:"false" cty:"restore_original" hcl:"restore_original"`
+	OnShutdown             *string `mapstructure:"on_shutdown" required:"false" cty:"on_shutdown" hcl:"on_shutdown"`
+	AutoShareCamera        *bool   `mapstructure:"auto_share_camera" required:"false" cty:"auto_share_camera" hcl:"auto_share_camera"`
+	SmartGuard             *bool   `mapstructure:"smart_guard" required:"false" cty:"smart_guard" hcl:"smart_guard"`
+	SharedCloud            *bool   `mapstructure:"shared_cloud" required:"false" cty:"shared_cloud" hcl:"shared_cloud"`
+	SharedProfile          *bool   `mapstructure:"shared_profile" required:"false" cty:"shared_profile" hcl:"shared_profile"`
+	SmartMount             *bool   `mapstructure:"smart_mount" required:"false" cty:"smart_mount" hcl:"smart_mount"`
+	ShareGuestApps         *bool   `mapstructure:"share_guest_apps" required:"false" cty:"share_guest_apps" hcl:"share_guest_apps"`
+	ShareHostApps          *bool   `mapstructure:"share_host_apps" required:"false" cty:"share_host_apps" hcl:"share_host_apps"`
+	ShowGuestNotifications *bool   `mapstructure:"show_guest_notifications" required:"false" cty:"show_guest_notifications" hcl:"show_guest_notifications"`
+}
+
+// FlatMapstructure returns a new FlatFinalVMConfig.
+// FlatFinalVMConfig is an auto-generated flat version of FinalVMConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*FinalVMConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatFinalVMConfig)
+}
+
+// HCL2Spec returns the hcl spec of a FinalVMConfig.
+// This spec is used by HCL to read the fields of FinalVMConfig.
+// The decoded values from this spec will then be applied to a FlatFinalVMConfig.
+func (*FlatFinalVMConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"startup_view":             &hcldec.AttrSpec{Name: "startup_view", Type: cty.String, Required: false},
+		"on_window_close":          &hcldec.AttrSpec{Name: "on_window_close", Type: cty.String, Required: false},
+		"restore_original":         &hcldec.AttrSpec{Name: "restore_original", Type: cty.Bool, Required: false},
+		"on_shutdown":              &hcldec.AttrSpec{Name: "on_shutdown", Type: cty.String, Required: false},
+		"auto_share_camera":        &hcldec.AttrSpec{Name: "auto_share_camera", Type: cty.Bool, Required: false},
+		"smart_guard":              &hcldec.AttrSpec{Name: "smart_guard", Type: cty.Bool, Required: false},
+		"shared_cloud":             &hcldec.AttrSpec{Name: "shared_cloud", Type: cty.Bool, Required: false},
+		"shared_profile":           &hcldec.AttrSpec{Name: "shared_profile", Type: cty.Bool, Required: false},
+		"smart_mount":              &hcldec.AttrSpec{Name: "smart_mount", Type: cty.Bool, Required: false},
+		"share_guest_apps":         &hcldec.AttrSpec{Name: "share_guest_apps", Type: cty.Bool, Required: false},
+		"share_host_apps":          &hcldec.AttrSpec{Name: "share_host_apps", Type: cty.Bool, Required: false},
+		"show_guest_notifications": &hcldec.AttrSpec{Name: "show_guest_notifications", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestFinalVMConfigPrepare(t *testing.T) {
+	// Test with empty
+	c := new(FinalVMConfig)
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if !c.IsEmpty() {
+		t.Fatal("should be empty")
+	}
+
+	// Test with bad values
+	c = new(FinalVMConfig)
+	c.OnShutdown = "foo"
+	c.StartupView = "bar"
+	errs = c.Prepare(interpolate.NewContext())
+	if len(errs) != 2 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestFinalVMConfig_Settings(t *testing.T) {
+	c := new(FinalVMConfig)
+	c.StartupView = "window"
+	c.SharedProfile = config.TriTrue
+	c.SmartGuard = config.TriFalse
+
+	settings := c.Settings()
+	expected := map[string]string{
+		"--startup-view":   "window",
+		"--shared-profile": "on",
+		"--smart-guard":    "off",
+	}
+	if len(settings) != len(expected) {
+		t.Fatalf("bad: %#v", settings)
+	}
+	for option, value := range expected {
+		if settings[option] != value {
+			t.Fatalf("bad %s: %q", option, settings[option])
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+)
+
+// DefaultMatchThreshold is the similarity between the screenshot and the
+// reference image needed to identify a screen, if none is given.
+const DefaultMatchThreshold = 0.9
+
+// imageGridSize is the size of the grid both images are reduced to before
+// being compared, which makes the comparison independent of the resolution.
+const imageGridSize = 64
+
+// ImageOCRWrapper identifies the screens by comparing the screenshots to
+// their reference images, with a normalized cross-correlation. Unlike the
+// other OCR libraries, it is pure Go and doesn't recognize any text.
+type ImageOCRWrapper struct {
+	matcher    *screenMatcher
+	references map[string]image.Image
+}
+
+func NewImageOCRWrapper(ScreenConfigs []BootScreenConfig) (*ImageOCRWrapper, error) {
+	matcher, err := newScreenMatcher(ScreenConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	imageOCRWrapper := ImageOCRWrapper{
+		matcher:    matcher,
+		references: make(map[string]image.Image),
+	}
+	for _, screenConfig := range ScreenConfigs {
+		if screenConfig.IsEmpty() {
+			continue
+		}
+		if screenConfig.MatchImage == "" {
+			return nil, fmt.Errorf("screen %q has no match_image, required by the image OCR library", screenConfig.ScreenName)
+		}
+		reference, err := decodeImage(screenConfig.MatchImage)
+		if err != nil {
+			return nil, fmt.Errorf("error reading match_image of screen %q: %s", screenConfig.ScreenName, err)
+		}
+		imageOCRWrapper.references[screenConfig.ScreenName] = reference
+	}
+
+	return &imageOCRWrapper, nil
+}
+
+func (c *ImageOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	screenshot, err := decodeImage(imagePath)
+	if err != nil {
+		return BootScreenConfig{}, nil, err
+	}
+
+	screenConfig, _, err := c.matcher.find(func(s *matcherScreen) (bool, error) {
+		similarity, err := compareImages(screenshot, c.references[s.config.ScreenName], s.config.MatchRegion)
+		if err != nil {
+			return false, fmt.Errorf("error comparing screen %q: %s", s.config.ScreenName, err)
+		}
+
+		threshold := s.config.Threshold
+		if threshold == 0 {
+			threshold = DefaultMatchThreshold
+		}
+		log.Printf("Similarity with screen %q: %.3f", s.config.ScreenName, similarity)
+		return similarity >= threshold, nil
+	})
+	if err != nil {
+		return BootScreenConfig{}, nil, err
+	}
+
+	log.Printf("Detected screen name : '%s'", screenConfig.ScreenName)
+	return screenConfig, nil, nil
+}
+
+func (c *ImageOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	return nil, errors.New("the image OCR library doesn't recognize text")
+}
+
+func (c *ImageOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
+	c.matcher.remove(screenName)
+}
+
+func decodeImage(fileName string) (image.Image, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// compareImages returns the similarity, up to 1, between the region of the
+// screenshot and the reference image. The region is [x, y, width, height],
+// the whole screenshot if empty.
+func compareImages(screenshot image.Image, reference image.Image, region []int) (float64, error) {
+	area := screenshot.Bounds()
+	referenceArea := reference.Bounds()
+	if len(region) == 4 {
+		r := image.Rect(region[0], region[1], region[0]+region[2], region[1]+region[3])
+		if !r.Add(area.Min).In(area) {
+			return 0, fmt.Errorf("region %v is out of the screenshot of %dx%d", region, area.Dx(), area.Dy())
+		}
+
+		// A reference image of the size of the screenshot is compared on the
+		// same region, otherwise it is the region itself
+		if referenceArea.Dx() == area.Dx() && referenceArea.Dy() == area.Dy() {
+			referenceArea = r.Add(referenceArea.Min)
+		}
+		area = r.Add(area.Min)
+	}
+
+	return correlation(grayGrid(screenshot, area), grayGrid(reference, referenceArea)), nil
+}
+
+// grayGrid reduces the area of the image to a grid of the average luminances
+// of its cells.
+func grayGrid(img image.Image, area image.Rectangle) []float64 {
+	grid := make([]float64, imageGridSize*imageGridSize)
+	counts := make([]int, len(grid))
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		row := (y - area.Min.Y) * imageGridSize / area.Dy()
+		for x := area.Min.X; x < area.Max.X; x++ {
+			col := (x - area.Min.X) * imageGridSize / area.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			i := row*imageGridSize + col
+			grid[i] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			counts[i]++
+		}
+	}
+
+	// Cells of areas smaller than the grid are filled with their neighbours
+	last := 0.0
+	for i := range grid {
+		if counts[i] > 0 {
+			last = grid[i] / float64(counts[i])
+		}
+		grid[i] = last
+	}
+	return grid
+}
+
+// correlation returns the normalized cross-correlation of the grids, from -1
+// to 1. Uniform grids, which have no variance, are compared by luminance.
+func correlation(a, b []float64) float64 {
+	meanA, meanB := mean(a), mean(b)
+
+	var ab, aa, bb float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		ab += da * db
+		aa += da * da
+		bb += db * db
+	}
+
+	const epsilon = 1e-6
+	if aa < epsilon && bb < epsilon {
+		return 1 - math.Abs(meanA-meanB)/0xffff
+	}
+	if aa < epsilon || bb < epsilon {
+		return 0
+	}
+	return ab / math.Sqrt(aa*bb)
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
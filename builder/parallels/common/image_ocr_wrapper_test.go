@@ -0,0 +1,147 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testScreen returns an image of the size, black with a white rectangle.
+func testScreen(width, height int, rect image.Rectangle) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (image.Point{x, y}).In(rect) {
+				img.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+	return img
+}
+
+func writeTestImage(t *testing.T, img image.Image) string {
+	file, err := os.CreateTemp(t.TempDir(), "*.png")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return file.Name()
+}
+
+func TestImageOCRWrapper_IdentifyCurrentScreen(t *testing.T) {
+	language := writeTestImage(t, testScreen(200, 100, image.Rect(20, 20, 80, 40)))
+	keyboard := writeTestImage(t, testScreen(200, 100, image.Rect(120, 60, 180, 90)))
+
+	ocrWrapper, err := NewImageOCRWrapper([]BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "language", MatchImage: language},
+		{ScreenName: "keyboard", MatchImage: keyboard},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]image.Image{
+		"language": testScreen(200, 100, image.Rect(20, 20, 80, 40)),
+		// The resolution doesn't matter
+		"keyboard": testScreen(400, 200, image.Rect(240, 120, 360, 180)),
+		"empty":    testScreen(200, 100, image.Rect(0, 0, 200, 10)),
+	}
+	for expected, screenshot := range cases {
+		screenConfig, _, err := ocrWrapper.IdentifyCurrentScreen(writeTestImage(t, screenshot))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if screenConfig.ScreenName != expected {
+			t.Errorf("bad screen: %s, expected: %s", screenConfig.ScreenName, expected)
+		}
+	}
+
+	ocrWrapper.RemoveBootScreenConfigIfExist("language")
+	screenConfig, _, err := ocrWrapper.IdentifyCurrentScreen(writeTestImage(t, cases["language"]))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if screenConfig.ScreenName != "empty" {
+		t.Fatalf("bad screen: %s", screenConfig.ScreenName)
+	}
+}
+
+func TestImageOCRWrapper_region(t *testing.T) {
+	// The button is compared, whatever the rest of the screen shows
+	button := testScreen(60, 20, image.Rect(10, 5, 50, 15))
+	screenshot := testScreen(200, 100, image.Rect(0, 0, 200, 100))
+	for y := 70; y < 90; y++ {
+		for x := 130; x < 190; x++ {
+			screenshot.Set(x, y, button.At(x-130, y-70))
+		}
+	}
+
+	for _, reference := range []image.Image{button, screenshot} {
+		ocrWrapper, err := NewImageOCRWrapper([]BootScreenConfig{
+			{ScreenName: "button", MatchImage: writeTestImage(t, reference), MatchRegion: []int{130, 70, 60, 20}},
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		screenConfig, _, err := ocrWrapper.IdentifyCurrentScreen(writeTestImage(t, screenshot))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if screenConfig.ScreenName != "button" {
+			t.Fatalf("bad screen: %q", screenConfig.ScreenName)
+		}
+	}
+
+	ocrWrapper, err := NewImageOCRWrapper([]BootScreenConfig{
+		{ScreenName: "button", MatchImage: writeTestImage(t, button), MatchRegion: []int{180, 70, 60, 20}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, _, err := ocrWrapper.IdentifyCurrentScreen(writeTestImage(t, screenshot)); err == nil {
+		t.Fatal("region out of the screenshot should error")
+	}
+}
+
+func TestNewImageOCRWrapper_noMatchImage(t *testing.T) {
+	_, err := NewImageOCRWrapper([]BootScreenConfig{
+		{ScreenName: "language", MatchingStrings: []string{"language"}},
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	_, err = NewImageOCRWrapper([]BootScreenConfig{
+		{ScreenName: "language", MatchImage: filepath.Join(t.TempDir(), "missing.png")},
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	a := []float64{1, 2, 3, 4}
+	if c := correlation(a, []float64{2, 4, 6, 8}); c < 0.999 {
+		t.Fatalf("bad: %f", c)
+	}
+	if c := correlation(a, []float64{4, 3, 2, 1}); c > -0.999 {
+		t.Fatalf("bad: %f", c)
+	}
+	if c := correlation([]float64{5, 5}, []float64{5, 5}); c != 1 {
+		t.Fatalf("bad: %f", c)
+	}
+	if c := correlation(a, []float64{5, 5, 5, 5}); c != 0 {
+		t.Fatalf("bad: %f", c)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultKeyboardLayout is the keyboard layout used unless another one is
+// configured.
+const DefaultKeyboardLayout = "us"
+
+// The PC-XT scancodes of the character keys, row by row: the number row,
+// the top row, the home row ending with the key next to Enter on ISO
+// keyboards (backslash on US keyboards), and the bottom row starting with the
+// ISO key next to the left Shift.
+var layoutScancodes = [4][]byte{
+	{0x29, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d},
+	{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b},
+	{0x1e, 0x1f, 0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x2b},
+	{0x56, 0x2c, 0x2d, 0x2e, 0x2f, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35},
+}
+
+// The characters of the keys of each row, by modifier. A space stands for a
+// key that doesn't produce any character.
+type layoutRows [4]string
+
+// keyboardLayouts are the supported layouts.
+var keyboardLayouts = map[string]*keyboardLayout{
+	"us": newKeyboardLayout("us",
+		layoutRows{"`1234567890-=", "qwertyuiop[]", "asdfghjkl;'\\", " zxcvbnm,./"},
+		layoutRows{"~!@#$%^&*()_+", "QWERTYUIOP{}", "ASDFGHJKL:\"|", " ZXCVBNM<>?"},
+		layoutRows{},
+		"",
+	),
+	"us-intl": newKeyboardLayout("us-intl",
+		layoutRows{"`1234567890-=", "qwertyuiop[]", "asdfghjkl;'\\", " zxcvbnm,./"},
+		layoutRows{"~!@#$%^&*()_+", "QWERTYUIOP{}", "ASDFGHJKL:\"|", " ZXCVBNM<>?"},
+		layoutRows{" ¡²³¤€¼½¾‘’¥×", "äåé®þüúíóö«»", "áßð     ø¶´¬", " æ ©  ñµç ¿"},
+		"`'\"~^",
+	),
+	"uk": newKeyboardLayout("uk",
+		layoutRows{"`1234567890-=", "qwertyuiop[]", "asdfghjkl;'#", "\\zxcvbnm,./"},
+		layoutRows{"¬!\"£$%^&*()_+", "QWERTYUIOP{}", "ASDFGHJKL:@~", "|ZXCVBNM<>?"},
+		layoutRows{"¦   €"},
+		"",
+	),
+	"de": newKeyboardLayout("de",
+		layoutRows{"^1234567890ß´", "qwertzuiopü+", "asdfghjklöä#", "<yxcvbnm,.-"},
+		layoutRows{"°!\"§$%&/()=?`", "QWERTZUIOPÜ*", "ASDFGHJKLÖÄ'", ">YXCVBNM;:_"},
+		layoutRows{"  ²³   {[]}\\ ", "@ €        ~", "", "|      µ"},
+		"^´`",
+	),
+	"fr": newKeyboardLayout("fr",
+		layoutRows{"²&é\"'(-è_çà)=", "azertyuiop^$", "qsdfghjklmù*", "<wxcvbn,;:!"},
+		layoutRows{" 1234567890°+", "AZERTYUIOP¨£", "QSDFGHJKLM%µ", ">WXCVBN?./§"},
+		layoutRows{"  ~#{[|`\\^@]}", "  €        ¤"},
+		"^¨~`",
+	),
+}
+
+// KeyboardLayouts returns the names of the supported keyboard layouts.
+func KeyboardLayouts() []string {
+	var names []string
+	for name := range keyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layoutKey is the key, and the modifiers, which produce a character.
+type layoutKey struct {
+	// The PRL keycode of the key
+	keycode int
+	shift   bool
+	altGr   bool
+	// Dead keys only produce their character when followed by a space.
+	dead bool
+}
+
+// keyboardLayout maps the characters to the keys which produce them.
+type keyboardLayout struct {
+	name string
+	keys map[rune]layoutKey
+}
+
+func newKeyboardLayout(name string, normal, shift, altGr layoutRows, dead string) *keyboardLayout {
+	l := &keyboardLayout{
+		name: name,
+		keys: map[rune]layoutKey{' ': {keycode: prlKeySpace}},
+	}
+	planes := []struct {
+		rows         layoutRows
+		shift, altGr bool
+	}{
+		{normal, false, false},
+		{shift, true, false},
+		{altGr, false, true},
+	}
+	for _, plane := range planes {
+		for row, chars := range plane.rows {
+			for i, r := range []rune(chars) {
+				if r == ' ' {
+					continue
+				}
+				if i >= len(layoutScancodes[row]) {
+					panic(fmt.Sprintf("keyboard layout %s: too many keys in row %d", name, row))
+				}
+				if _, ok := l.keys[r]; ok {
+					continue
+				}
+				l.keys[r] = layoutKey{
+					keycode: getKeycodeFromScanCode([]uint{uint(layoutScancodes[row][i])}),
+					shift:   plane.shift,
+					altGr:   plane.altGr,
+					dead:    strings.ContainsRune(dead, r),
+				}
+			}
+		}
+	}
+	return l
+}
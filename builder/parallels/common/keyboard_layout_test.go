@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// typeWithLayout types the boot command with the keyboard layout and returns
+// the key events sent, as "+keycode" for a press and "-keycode" for a release.
+func typeWithLayout(t *testing.T, layout, command string) []string {
+	var sent []string
+	d, err := NewKeycodeDriver(func(events []KeyEvent) error {
+		for _, event := range events {
+			if event.Event == KeyEventPress {
+				sent = append(sent, fmt.Sprintf("+%d", event.Key))
+			} else {
+				sent = append(sent, fmt.Sprintf("-%d", event.Key))
+			}
+		}
+		return nil
+	}, layout, 0, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(command)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return sent
+}
+
+func testKeyboardLayout(t *testing.T, layout string, cases map[string]string) {
+	for command, expected := range cases {
+		sent := strings.Join(typeWithLayout(t, layout, command), " ")
+		if sent != expected {
+			t.Errorf("%s %q: expected %q, got %q", layout, command, expected, sent)
+		}
+	}
+}
+
+func TestKeyboardLayout_us(t *testing.T) {
+	testKeyboardLayout(t, "us", map[string]string{
+		"/":  "+61 -61",
+		"@":  "+50 +11 -11 -50",
+		"-":  "+20 -20",
+		"z:": "+52 -52 +50 +47 -47 -50",
+	})
+}
+
+func TestKeyboardLayout_usIntl(t *testing.T) {
+	testKeyboardLayout(t, "us-intl", map[string]string{
+		"/":  "+61 -61",
+		"@":  "+50 +11 -11 -50",
+		"'":  "+48 -48 +65 -65",
+		"\"": "+50 +48 -48 -50 +65 -65",
+		"é":  "+113 +26 -26 -113",
+	})
+}
+
+func TestKeyboardLayout_uk(t *testing.T) {
+	testKeyboardLayout(t, "uk", map[string]string{
+		"/":  "+61 -61",
+		"@":  "+50 +48 -48 -50",
+		"\"": "+50 +11 -11 -50",
+		"#":  "+51 -51",
+		"\\": "+94 -94",
+		"|":  "+50 +94 -94 -50",
+		"€":  "+113 +13 -13 -113",
+	})
+}
+
+func TestKeyboardLayout_de(t *testing.T) {
+	testKeyboardLayout(t, "de", map[string]string{
+		"/":  "+50 +16 -16 -50",
+		"-":  "+61 -61",
+		"@":  "+113 +24 -24 -113",
+		":":  "+50 +60 -60 -50",
+		"zY": "+29 -29 +50 +52 -52 -50",
+		"\\": "+113 +20 -20 -113",
+		"^":  "+49 -49 +65 -65",
+		"<":  "+94 -94",
+		"|":  "+113 +94 -94 -113",
+	})
+}
+
+func TestKeyboardLayout_fr(t *testing.T) {
+	testKeyboardLayout(t, "fr", map[string]string{
+		"/":  "+50 +60 -60 -50",
+		"-":  "+15 -15",
+		"@":  "+113 +19 -19 -113",
+		":":  "+60 -60",
+		"aq": "+24 -24 +38 -38",
+		"1":  "+50 +10 -10 -50",
+		"~":  "+113 +11 -11 -113 +65 -65",
+		".":  "+50 +59 -59 -50",
+	})
+}
+
+func TestKeyboardLayout_special(t *testing.T) {
+	sent := typeWithLayout(t, "de", "<enter>y<leftShiftOn>z<leftShiftOff>")
+	expected := []string{"+36", "-36", "+52", "-52", "+50", "+29", "-29", "-50"}
+	if !reflect.DeepEqual(sent, expected) {
+		t.Fatalf("bad: %#v", sent)
+	}
+}
+
+func TestKeyboardLayout_unknownCharacter(t *testing.T) {
+	d, err := NewKeycodeDriver(func([]KeyEvent) error { return nil }, "de", 0, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.SendKey('ñ', bootcommand.KeyPress); err == nil {
+		t.Fatal("should error")
+	}
+
+	if _, err := NewKeycodeDriver(func([]KeyEvent) error { return nil }, "xx", 0, 0); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestBootKeyboardConfigPrepare(t *testing.T) {
+	c := new(BootKeyboardConfig)
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.BootKeyboardLayout != DefaultKeyboardLayout {
+		t.Fatalf("bad: %s", c.BootKeyboardLayout)
+	}
+
+	for _, layout := range KeyboardLayouts() {
+		c = &BootKeyboardConfig{BootKeyboardLayout: layout}
+		if errs := c.Prepare(nil); len(errs) > 0 {
+			t.Fatalf("%s: %#v", layout, errs)
+		}
+	}
+
+	c = &BootKeyboardConfig{BootKeyboardLayout: "xx"}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// KeyEvent is a key event sent to the VM by "prlctl send-key-event -j".
+type KeyEvent struct {
+	// The PRL keycode of the key, see prlKeycode2ScanCode.
+	Key int `json:"key"`
+	// Either KeyEventPress or KeyEventRelease.
+	Event string `json:"event"`
+	// The delay after the event, in milliseconds.
+	Delay int `json:"delay"`
+}
+
+// The types of key events.
+const (
+	KeyEventPress   = "press"
+	KeyEventRelease = "release"
+)
+
+// The PRL keycodes of the keys typed along with the characters.
+const (
+	prlKeyLeftShift = 50
+	prlKeySpace     = 65
+	prlKeyRightAlt  = 113
+)
+
+// prlSpecialKeys are the PRL keycodes of the special keys of the boot command.
+var prlSpecialKeys = map[string]int{
+	"bs":         22,
+	"del":        107,
+	"down":       104,
+	"end":        103,
+	"enter":      36,
+	"esc":        9,
+	"f1":         67,
+	"f2":         68,
+	"f3":         69,
+	"f4":         70,
+	"f5":         71,
+	"f6":         72,
+	"f7":         73,
+	"f8":         74,
+	"f9":         75,
+	"f10":        76,
+	"f11":        95,
+	"f12":        96,
+	"home":       97,
+	"insert":     106,
+	"left":       100,
+	"leftalt":    64,
+	"leftctrl":   37,
+	"leftshift":  prlKeyLeftShift,
+	"leftsuper":  115,
+	"menu":       117,
+	"pagedown":   105,
+	"pageup":     99,
+	"return":     36,
+	"right":      102,
+	"rightalt":   prlKeyRightAlt,
+	"rightctrl":  109,
+	"rightshift": 62,
+	"rightsuper": 116,
+	"spacebar":   prlKeySpace,
+	"tab":        23,
+	"up":         98,
+
+	// The Command and Option keys of macOS guests are the Windows and Alt
+	// keys of PC keyboards.
+	"leftcommand":  115,
+	"rightcommand": 116,
+	"leftoption":   64,
+	"rightoption":  prlKeyRightAlt,
+}
+
+// SendKeyEventsFunc is called to send the key events of a group to the VM.
+type SendKeyEventsFunc func([]KeyEvent) error
+
+// keycodeDriver is a bootcommand.BCDriver emitting PRL keycodes. The key
+// events are buffered until the group of keys is flushed, and sent at once.
+type keycodeDriver struct {
+	layout   *keyboardLayout
+	sendImpl SendKeyEventsFunc
+	// The delay after each key event, in milliseconds
+	keyDelay int
+	interval time.Duration
+	buffer   []KeyEvent
+}
+
+// NewKeycodeDriver returns the driver typing the boot command with the given
+// keyboard layout. keyInterval is the delay after each key event, and
+// groupInterval the delay after each group of keys.
+func NewKeycodeDriver(send SendKeyEventsFunc, layout string, keyInterval, groupInterval time.Duration) (bootcommand.BCDriver, error) {
+	if layout == "" {
+		layout = DefaultKeyboardLayout
+	}
+	l, ok := keyboardLayouts[layout]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout: %s", layout)
+	}
+
+	return &keycodeDriver{
+		layout:   l,
+		sendImpl: send,
+		keyDelay: int(keyInterval / time.Millisecond),
+		interval: groupInterval,
+	}, nil
+}
+
+func (d *keycodeDriver) press(keycode int) {
+	d.buffer = append(d.buffer, KeyEvent{Key: keycode, Event: KeyEventPress, Delay: d.keyDelay})
+}
+
+func (d *keycodeDriver) release(keycode int) {
+	d.buffer = append(d.buffer, KeyEvent{Key: keycode, Event: KeyEventRelease, Delay: d.keyDelay})
+}
+
+// SendKey buffers the events of the key and modifiers producing the
+// character with the keyboard layout.
+func (d *keycodeDriver) SendKey(key rune, action bootcommand.KeyAction) error {
+	k, ok := d.layout.keys[key]
+	if !ok {
+		return fmt.Errorf("character %q can't be typed with the %s keyboard layout", key, d.layout.name)
+	}
+	log.Printf("Sending char '%c' with the %s keyboard layout, keycode %d, shift %v, altgr %v",
+		key, d.layout.name, k.keycode, k.shift, k.altGr)
+
+	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 {
+		if k.altGr {
+			d.press(prlKeyRightAlt)
+		}
+		if k.shift {
+			d.press(prlKeyLeftShift)
+		}
+		d.press(k.keycode)
+	}
+
+	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
+		d.release(k.keycode)
+		if k.shift {
+			d.release(prlKeyLeftShift)
+		}
+		if k.altGr {
+			d.release(prlKeyRightAlt)
+		}
+		if k.dead {
+			d.press(prlKeySpace)
+			d.release(prlKeySpace)
+		}
+	}
+	return nil
+}
+
+// SendSpecial buffers the events of the special key.
+func (d *keycodeDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
+	keycode, ok := prlSpecialKeys[special]
+	if !ok {
+		return fmt.Errorf("special %s not found.", special)
+	}
+	log.Printf("Special code '%s' '<%s>' found, replacing with keycode %d", action.String(), special, keycode)
+
+	switch action {
+	case bootcommand.KeyOn:
+		d.press(keycode)
+	case bootcommand.KeyOff:
+		d.release(keycode)
+	case bootcommand.KeyPress:
+		d.press(keycode)
+		d.release(keycode)
+	}
+	return nil
+}
+
+// Flush sends the buffered key events.
+func (d *keycodeDriver) Flush() error {
+	defer func() {
+		d.buffer = nil
+	}()
+	if len(d.buffer) == 0 {
+		return nil
+	}
+	if err := d.sendImpl(d.buffer); err != nil {
+		return err
+	}
+	time.Sleep(d.interval)
+	return nil
+}
+
+// keyEventScancodes converts the key events to PC-XT scancodes.
+func keyEventScancodes(events []KeyEvent) []string {
+	var codes []string
+	for _, event := range events {
+		if event.Key < 0 || event.Key >= len(prlKeycode2ScanCode) {
+			log.Printf("No scancode for keycode %d", event.Key)
+			continue
+		}
+		sc := prlKeycode2ScanCode[event.Key]
+		for i, b := range sc {
+			// The break code of a key is its make code with the high bit
+			// set, the 0xE0 prefix of extended keys excepted.
+			if event.Event == KeyEventRelease && i == len(sc)-1 {
+				b |= 0x80
+			}
+			codes = append(codes, fmt.Sprintf("%02x", b))
+		}
+	}
+	return codes
+}
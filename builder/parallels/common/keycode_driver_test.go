@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+func TestKeycodeDriver_groups(t *testing.T) {
+	var groups [][]KeyEvent
+	d, err := NewKeycodeDriver(func(events []KeyEvent) error {
+		groups = append(groups, events)
+		return nil
+	}, "us", 20*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence("ab<wait1ms><enter>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := [][]KeyEvent{
+		{
+			{Key: 38, Event: KeyEventPress, Delay: 20},
+			{Key: 38, Event: KeyEventRelease, Delay: 20},
+			{Key: 56, Event: KeyEventPress, Delay: 20},
+			{Key: 56, Event: KeyEventRelease, Delay: 20},
+		},
+		{
+			{Key: 36, Event: KeyEventPress, Delay: 20},
+			{Key: 36, Event: KeyEventRelease, Delay: 20},
+		},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("bad: %#v", groups)
+	}
+}
+
+func TestKeycodeDriver_macModifiers(t *testing.T) {
+	cases := map[string][]string{
+		"<leftCommandOn>q<leftCommandOff>":   {"+115", "+24", "-24", "-115"},
+		"<rightCommandOn>q<rightCommandOff>": {"+116", "+24", "-24", "-116"},
+		"<leftOptionOn>e<leftOptionOff>":     {"+64", "+26", "-26", "-64"},
+		"<rightOption>":                      {"+113", "-113"},
+	}
+	for command, expected := range cases {
+		sent := typeWithLayout(t, "us", command)
+		if !reflect.DeepEqual(sent, expected) {
+			t.Errorf("%q: bad: %#v", command, sent)
+		}
+	}
+}
+
+func TestKeyEventScancodes(t *testing.T) {
+	events := []KeyEvent{
+		{Key: prlKeyLeftShift, Event: KeyEventPress},
+		{Key: 38, Event: KeyEventPress},
+		{Key: 38, Event: KeyEventRelease},
+		{Key: prlKeyLeftShift, Event: KeyEventRelease},
+		{Key: prlKeyRightAlt, Event: KeyEventPress},
+		{Key: prlKeyRightAlt, Event: KeyEventRelease},
+	}
+
+	codes := keyEventScancodes(events)
+	expected := []string{"2a", "1e", "9e", "aa", "e0", "38", "e0", "b8"}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf("bad: %#v", codes)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ManifestFileName is the name of the build manifest written to the output
+// directory.
+const ManifestFileName = "packer-manifest.json"
+
+// Manifest is a machine-readable description of the built VM. It is written
+// to the output directory and exposed through the artifact state, so that
+// downstream automation doesn't have to inspect the VM again.
+type Manifest struct {
+	VMName           string         `json:"vm_name"`
+	UUID             string         `json:"uuid"`
+	MACAddresses     []string       `json:"mac_addresses"`
+	GuestOS          string         `json:"guest_os"`
+	CPUs             int            `json:"cpus"`
+	MemoryMB         int            `json:"memory_mb"`
+	Disks            []ManifestDisk `json:"disks"`
+	Files            []ManifestFile `json:"files"`
+	ParallelsVersion string         `json:"parallels_version"`
+	Host             string         `json:"host"`
+	HostArch         string         `json:"host_arch"`
+	Source           string         `json:"source"`
+	SourceChecksum   string         `json:"source_checksum"`
+	BuildDuration    string         `json:"build_duration"`
+}
+
+// ManifestDisk describes a virtual disk of the built VM.
+type ManifestDisk struct {
+	Name               string `json:"name"`
+	Path               string `json:"path"`
+	Format             string `json:"format"`
+	VirtualSizeBytes   int64  `json:"virtual_size_bytes"`
+	AllocatedSizeBytes int64  `json:"allocated_size_bytes"`
+}
+
+// ManifestFile describes a file of the artifact. The path is relative to
+// the output directory.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// stateData returns the fields of the manifest keyed by their JSON names, as
+// the values the artifact state can carry to the post-processors, which run
+// in other processes: the scalars as strings, the MAC addresses and the disk
+// names as lists of strings, and the files as a map of their paths to their
+// checksums. The whole manifest is available as JSON under "manifest".
+func (m *Manifest) stateData() map[string]interface{} {
+	disks := make([]string, 0, len(m.Disks))
+	for _, disk := range m.Disks {
+		disks = append(disks, disk.Name)
+	}
+	files := make(map[string]string, len(m.Files))
+	for _, file := range m.Files {
+		files[file.Path] = file.SHA256
+	}
+	macAddresses := append([]string{}, m.MACAddresses...)
+
+	data := map[string]interface{}{
+		"vm_name":           m.VMName,
+		"uuid":              m.UUID,
+		"mac_addresses":     macAddresses,
+		"guest_os":          m.GuestOS,
+		"cpus":              strconv.Itoa(m.CPUs),
+		"memory_mb":         strconv.Itoa(m.MemoryMB),
+		"disks":             disks,
+		"files":             &files,
+		"parallels_version": m.ParallelsVersion,
+		"host":              m.Host,
+		"host_arch":         m.HostArch,
+		"source":            m.Source,
+		"source_checksum":   m.SourceChecksum,
+		"build_duration":    m.BuildDuration,
+	}
+	if raw, err := json.Marshal(m); err == nil {
+		data["manifest"] = string(raw)
+	}
+	return data
+}
+
+// write hashes the given files and writes the manifest into the directory.
+// It returns the path to the manifest.
+func (m *Manifest) write(dir string, files []string) (string, error) {
+	m.Files = make([]ManifestFile, 0, len(files))
+	for _, path := range files {
+		sum, err := sha256File(path)
+		if err != nil {
+			return "", err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		m.Files = append(m.Files, ManifestFile{
+			Path:   filepath.ToSlash(rel),
+			SHA256: sum,
+		})
+	}
+
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, ManifestFileName)
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// allocatedSize returns the total size of the files of a virtual disk.
+// Expanding disks only grow as data is written, so this is the space
+// actually allocated for the disk.
+func allocatedSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
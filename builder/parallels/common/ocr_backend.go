@@ -0,0 +1,143 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ocrBackendTimeout is the maximum duration of the recognition of a
+// screenshot by an OCR backend.
+const ocrBackendTimeout = time.Minute
+
+// OCRBackend recognizes the words of a PNG image, along with their bounding
+// boxes and confidence.
+type OCRBackend interface {
+	Recognize(png []byte) ([]OCRText, error)
+}
+
+// CommandOCRBackend runs a command, which reads the PNG image from its
+// standard input and writes the JSON array of the words to its standard
+// output.
+type CommandOCRBackend struct {
+	Command []string
+}
+
+func (b *CommandOCRBackend) Recognize(png []byte) ([]OCRText, error) {
+	if len(b.Command) == 0 {
+		return nil, fmt.Errorf("no OCR command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ocrBackendTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, b.Command[0], b.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(png)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running the OCR command: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseOCRBackendResponse(stdout.Bytes())
+}
+
+// HTTPOCRBackend posts the PNG image to an URL, which replies with the JSON
+// array of the words.
+type HTTPOCRBackend struct {
+	URL    string
+	Client *http.Client
+}
+
+func (b *HTTPOCRBackend) Recognize(png []byte) ([]OCRText, error) {
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: ocrBackendTimeout}
+	}
+
+	resp, err := client.Post(b.URL, "image/png", bytes.NewReader(png))
+	if err != nil {
+		return nil, fmt.Errorf("error posting to the OCR service: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the response of the OCR service: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the OCR service replied %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return parseOCRBackendResponse(body)
+}
+
+func parseOCRBackendResponse(data []byte) ([]OCRText, error) {
+	var texts []OCRText
+	if err := json.Unmarshal(data, &texts); err != nil {
+		return nil, fmt.Errorf("invalid response of the OCR backend, expected a JSON array of words: %s", err)
+	}
+	return texts, nil
+}
+
+// BackendOCRWrapper identifies the screens from the words recognized by an
+// OCR backend.
+type BackendOCRWrapper struct {
+	backend OCRBackend
+	matcher *screenMatcher
+}
+
+func NewBackendOCRWrapper(backend OCRBackend, ScreenConfigs []BootScreenConfig) (*BackendOCRWrapper, error) {
+	matcher, err := newScreenMatcher(ScreenConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackendOCRWrapper{
+		backend: backend,
+		matcher: matcher,
+	}, nil
+}
+
+func (c *BackendOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	texts, err := c.RecognizeText(imagePath)
+	if err != nil {
+		return BootScreenConfig{}, nil, err
+	}
+
+	screenTexts, err := locateImageTexts(texts, imagePath)
+	if err != nil {
+		return BootScreenConfig{}, nil, err
+	}
+
+	log.Println("Detected text: ", screenTexts)
+	screenConfig, _ := c.matcher.matchWords(screenTexts)
+	if screenConfig.IsEmpty() {
+		c.matcher.logNearMisses(screenTexts)
+	}
+	return screenConfig, screenTexts, nil
+}
+
+func (c *BackendOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	png, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	return c.backend.Recognize(png)
+}
+
+func (c *BackendOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
+	c.matcher.remove(screenName)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testOCRBackendResponse = `[
+	{"text": "Select your", "x": 10, "y": 20, "width": 100, "height": 12, "confidence": 0.9},
+	{"text": "Language", "x": 115, "y": 20, "width": 80, "height": 12, "confidence": 0.8}
+]`
+
+var testOCRBackendTexts = []OCRText{
+	{Text: "Select your", X: 10, Y: 20, Width: 100, Height: 12, Confidence: 0.9},
+	{Text: "Language", X: 115, Y: 20, Width: 80, Height: 12, Confidence: 0.8},
+}
+
+func testOCRBackendScreens() []BootScreenConfig {
+	return []BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "keyboard", MatchingStrings: []string{"keyboard"}},
+		{ScreenName: "language", MatchingStrings: []string{"select your language"}},
+	}
+}
+
+// writeTestScreenshot writes a blank screenshot of 200x100 pixels.
+func writeTestScreenshot(t *testing.T) string {
+	screenshot := filepath.Join(t.TempDir(), "screenshot.png")
+	file, err := os.Create(screenshot)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return screenshot
+}
+
+func readTestScreenshot(t *testing.T, screenshot string) string {
+	data, err := os.ReadFile(screenshot)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return string(data)
+}
+
+func TestCommandOCRBackend(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	response := filepath.Join(dir, "response.json")
+	if err := os.WriteFile(response, []byte(testOCRBackendResponse), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The stub keeps the image it reads, and replies with the response
+	ocrWrapper, err := NewOCRWrapper(OCRConfig{
+		OCRLibrary: "command",
+		OCRCommand: []string{"sh", "-c", `cat > "$0" && cat "$1"`, input, response},
+	}, testOCRBackendScreens())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	screenshot := writeTestScreenshot(t)
+	texts, err := ocrWrapper.RecognizeText(screenshot)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(texts, testOCRBackendTexts) {
+		t.Fatalf("bad: %#v", texts)
+	}
+	if data, err := os.ReadFile(input); err != nil || string(data) != readTestScreenshot(t, screenshot) {
+		t.Fatalf("bad input: %q, %v", data, err)
+	}
+
+	screenConfig, screenTexts, err := ocrWrapper.IdentifyCurrentScreen(screenshot)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if screenConfig.ScreenName != "language" {
+		t.Fatalf("bad screen: %s", screenConfig.ScreenName)
+	}
+	// The locations are relative to the size of the screenshot
+	if found := screenTexts.Find("Language"); found.X != 0.575 || found.Y != 0.2 || found.CenterX() != 155 {
+		t.Fatalf("bad location: %#v", found)
+	}
+}
+
+func TestCommandOCRBackend_errors(t *testing.T) {
+	commands := [][]string{
+		{"sh", "-c", "echo failure >&2; exit 1"},
+		{"sh", "-c", "echo not json"},
+	}
+	for _, command := range commands {
+		backend := &CommandOCRBackend{Command: command}
+		if _, err := backend.Recognize([]byte("png")); err == nil {
+			t.Errorf("%v: should have error", command)
+		}
+	}
+}
+
+func TestHTTPOCRBackend(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "bad method", http.StatusMethodNotAllowed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		contentType, body = r.Header.Get("Content-Type"), string(data)
+		io.WriteString(w, testOCRBackendResponse)
+	}))
+	defer server.Close()
+
+	ocrWrapper, err := NewOCRWrapper(OCRConfig{
+		OCRLibrary: "http",
+		OCRURL:     server.URL,
+	}, testOCRBackendScreens())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	screenshot := writeTestScreenshot(t)
+	screenConfig, _, err := ocrWrapper.IdentifyCurrentScreen(screenshot)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if screenConfig.ScreenName != "language" {
+		t.Fatalf("bad screen: %s", screenConfig.ScreenName)
+	}
+	if contentType != "image/png" || body != readTestScreenshot(t, screenshot) {
+		t.Fatalf("bad request: %s, %q", contentType, body)
+	}
+}
+
+func TestHTTPOCRBackend_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	backend := &HTTPOCRBackend{URL: server.URL}
+	if _, err := backend.Recognize([]byte("png")); err == nil {
+		t.Fatal("should have error")
+	}
+}
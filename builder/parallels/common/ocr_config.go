@@ -0,0 +1,78 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// The OCR libraries recognizing the screens.
+var ocrLibraries = []string{"vision", "tesseract", "image", "command", "http"}
+
+// OCRConfig contains the configuration of the OCR library recognizing the
+// text on the screen, for the screen based boot and the text directives of
+// the boot command.
+//
+// The "command" and "http" libraries are backends of your own, e.g. a local
+// OCR service. They receive the screenshot as a PNG image and reply with the
+// JSON array of the recognized words, e.g.
+//
+// ```json
+// [{"text": "Continue", "x": 860, "y": 700, "width": 120, "height": 24, "confidence": 0.98}]
+// ```
+//
+// where the bounding box is in pixels, the origin being the top left corner
+// of the image, and the confidence is from 0 to 1.
+type OCRConfig struct {
+	// OCR library to use. Five options are currently supported: "tesseract", "vision", "image",
+	// "command" and "http".
+	// "tesseract" uses the Tesseract OCR library to recognize text. A manual installation of -
+	// Tesseract is required for this to work.
+	// "vision" uses the Apple Vision library to recognize text, which is included in macOS. It might -
+	// cause problems in macOS 13 or older VMs.
+	// "image" doesn't recognize text, but compares the screenshots to the `match_image` of the screens.
+	// It works on any platform.
+	// "command" runs `ocr_command` and "http" posts to `ocr_url`.
+	// Defaults to "vision".
+	OCRLibrary string `mapstructure:"ocr_library" required:"false"`
+	// The command, and its arguments, of the "command" OCR library. The
+	// screenshot is written to its standard input, and the words are read
+	// from its standard output.
+	OCRCommand []string `mapstructure:"ocr_command" required:"false"`
+	// The URL of the "http" OCR library. The screenshot is the body of a POST
+	// request, with the `image/png` content type, and the words are the body
+	// of the response.
+	OCRURL string `mapstructure:"ocr_url" required:"false"`
+}
+
+// Prepare sets the default OCR library and validates its settings.
+func (c *OCRConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	if c.OCRLibrary == "" {
+		c.OCRLibrary = "vision"
+	}
+
+	switch c.OCRLibrary {
+	case "vision", "tesseract", "image":
+	case "command":
+		if len(c.OCRCommand) == 0 {
+			errs = append(errs, fmt.Errorf("ocr_command must be specified with the command ocr_library"))
+		}
+	case "http":
+		u, err := url.Parse(c.OCRURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("ocr_url must be an http or https URL with the http ocr_library"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid ocr_library: %s. Allowed values are : %v", c.OCRLibrary, ocrLibraries))
+	}
+
+	return errs
+}
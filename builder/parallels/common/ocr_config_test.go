@@ -0,0 +1,44 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestOCRConfigPrepare(t *testing.T) {
+	c := new(OCRConfig)
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.OCRLibrary != "vision" {
+		t.Fatalf("bad: %s", c.OCRLibrary)
+	}
+
+	valid := []OCRConfig{
+		{OCRLibrary: "tesseract"},
+		{OCRLibrary: "image"},
+		{OCRLibrary: "command", OCRCommand: []string{"paddleocr-json"}},
+		{OCRLibrary: "http", OCRURL: "http://localhost:8866/ocr"},
+	}
+	for _, c := range valid {
+		if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+			t.Errorf("%#v: err: %#v", c, errs)
+		}
+	}
+
+	invalid := []OCRConfig{
+		{OCRLibrary: "paddle"},
+		{OCRLibrary: "command"},
+		{OCRLibrary: "http"},
+		{OCRLibrary: "http", OCRURL: "localhost:8866"},
+	}
+	for _, c := range invalid {
+		if errs := c.Prepare(interpolate.NewContext()); len(errs) == 0 {
+			t.Errorf("%#v: should have error", c)
+		}
+	}
+}
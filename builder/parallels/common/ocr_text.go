@@ -0,0 +1,251 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OCRText is a line of text recognized in a screenshot, along with its
+// bounding box in pixels. The origin is the top left corner of the image.
+type OCRText struct {
+	Text       string  `json:"text"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Center returns the coordinates of the center of the bounding box.
+func (t OCRText) Center() (int, int) {
+	return t.X + t.Width/2, t.Y + t.Height/2
+}
+
+// findText returns the first occurrence of the text, case insensitive, in the
+// recognized lines. The bounding box of a part of a line is estimated from
+// the position of the text in the line.
+func findText(texts []OCRText, text string) (OCRText, bool) {
+	for _, t := range texts {
+		matched, start, length, total, ok := findInLine(t.Text, text)
+		if !ok {
+			continue
+		}
+		return OCRText{
+			Text:       matched,
+			X:          t.X + t.Width*start/total,
+			Y:          t.Y,
+			Width:      t.Width * length / total,
+			Height:     t.Height,
+			Confidence: t.Confidence,
+		}, true
+	}
+	return OCRText{}, false
+}
+
+// findInLine returns the text found, case insensitive, in the line, along
+// with its position and length in characters, and the length of the line.
+func findInLine(line string, text string) (string, int, int, int, bool) {
+	needle := strings.ToLower(strings.TrimSpace(text))
+	if needle == "" {
+		return "", 0, 0, 0, false
+	}
+
+	lower := strings.ToLower(line)
+	i := strings.Index(lower, needle)
+	if i < 0 {
+		return "", 0, 0, 0, false
+	}
+
+	total := utf8.RuneCountInString(lower)
+	start := utf8.RuneCountInString(lower[:i])
+	length := utf8.RuneCountInString(needle)
+	// Lowercasing seldom changes the number of characters
+	matched := needle
+	if runes := []rune(line); len(runes) == total {
+		matched = string(runes[start : start+length])
+	}
+	return matched, start, length, total, true
+}
+
+// ScreenText is a text recognized on the screen, along with its bounding box
+// relative to the size of the screen: from 0 to 1, the origin being the top
+// left corner. Texts recognized without their location have an empty
+// bounding box.
+type ScreenText struct {
+	Text       string
+	X          float64
+	Y          float64
+	Width      float64
+	Height     float64
+	Confidence float64
+	// The size of the screen in pixels
+	ScreenWidth  int
+	ScreenHeight int
+}
+
+// CenterX returns the abscissa of the center of the bounding box in pixels,
+// as expected by the click directive.
+func (t ScreenText) CenterX() int {
+	return int(math.Round((t.X + t.Width/2) * float64(t.ScreenWidth)))
+}
+
+// CenterY returns the ordinate of the center of the bounding box in pixels,
+// as expected by the click directive.
+func (t ScreenText) CenterY() int {
+	return int(math.Round((t.Y + t.Height/2) * float64(t.ScreenHeight)))
+}
+
+// located returns whether the location of the text is known.
+func (t ScreenText) located() bool {
+	return t.Width > 0 && t.Height > 0
+}
+
+// in returns whether the center of the text is in the region, which is
+// [x, y, width, height] relative to the size of the screen.
+func (t ScreenText) in(region []float64) bool {
+	x, y := t.X+t.Width/2, t.Y+t.Height/2
+	return t.located() &&
+		x >= region[0] && x <= region[0]+region[2] &&
+		y >= region[1] && y <= region[1]+region[3]
+}
+
+// ScreenTexts are the texts recognized on the screen. The boot command of a
+// screen gets them as the Texts template data.
+type ScreenTexts []ScreenText
+
+// Find returns the first occurrence of the text, case insensitive, or an
+// empty text if it isn't on the screen. The bounding box of a part of a line
+// is estimated from the position of the text in the line.
+func (texts ScreenTexts) Find(text string) ScreenText {
+	for _, t := range texts {
+		matched, start, length, total, ok := findInLine(t.Text, text)
+		if !ok {
+			continue
+		}
+		found := t
+		found.Text = matched
+		found.X = t.X + t.Width*float64(start)/float64(total)
+		found.Width = t.Width * float64(length) / float64(total)
+		return found
+	}
+	return ScreenText{}
+}
+
+// String joins the texts.
+func (texts ScreenTexts) String() string {
+	var words []string
+	for _, text := range texts {
+		words = append(words, text.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+// locateTexts returns the texts with their bounding boxes relative to the
+// size of the screen, in pixels.
+func locateTexts(texts []OCRText, width int, height int) ScreenTexts {
+	located := make(ScreenTexts, 0, len(texts))
+	for _, t := range texts {
+		s := ScreenText{
+			Text:         t.Text,
+			Confidence:   t.Confidence,
+			ScreenWidth:  width,
+			ScreenHeight: height,
+		}
+		if width > 0 && height > 0 {
+			s.X = float64(t.X) / float64(width)
+			s.Y = float64(t.Y) / float64(height)
+			s.Width = float64(t.Width) / float64(width)
+			s.Height = float64(t.Height) / float64(height)
+		}
+		located = append(located, s)
+	}
+	return located
+}
+
+// locateImageTexts returns the texts recognized in the image with their
+// bounding boxes relative to the size of the image.
+func locateImageTexts(texts []OCRText, imagePath string) (ScreenTexts, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the image: %s", err)
+	}
+	return locateTexts(texts, config.Width, config.Height), nil
+}
+
+// parseTesseractTSV returns the lines of the output of "tesseract IMAGE
+// stdout tsv". The words of a line are joined, their bounding boxes merged.
+func parseTesseractTSV(tsv string) ([]OCRText, error) {
+	var texts []OCRText
+	var words int
+	lastLine := ""
+
+	for i, row := range strings.Split(tsv, "\n") {
+		row = strings.TrimRight(row, "\r")
+		if i == 0 || row == "" {
+			// Header
+			continue
+		}
+
+		fields := strings.Split(row, "\t")
+		if len(fields) < 12 {
+			return nil, fmt.Errorf("invalid tesseract output line %d: %q", i+1, row)
+		}
+		// Only the words are of interest
+		if fields[0] != "5" || strings.TrimSpace(fields[11]) == "" {
+			continue
+		}
+
+		var box [4]int
+		for j := range box {
+			v, err := strconv.Atoi(fields[6+j])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tesseract output line %d: %s", i+1, err)
+			}
+			box[j] = v
+		}
+		conf, _ := strconv.ParseFloat(fields[10], 64)
+		word := OCRText{
+			Text:       fields[11],
+			X:          box[0],
+			Y:          box[1],
+			Width:      box[2],
+			Height:     box[3],
+			Confidence: conf / 100,
+		}
+
+		// The page, block, paragraph and line numbers identify the line
+		line := strings.Join(fields[1:5], ".")
+		if line != lastLine || len(texts) == 0 {
+			texts = append(texts, word)
+			words = 1
+			lastLine = line
+			continue
+		}
+
+		t := &texts[len(texts)-1]
+		right := max(t.X+t.Width, word.X+word.Width)
+		bottom := max(t.Y+t.Height, word.Y+word.Height)
+		t.X = min(t.X, word.X)
+		t.Y = min(t.Y, word.Y)
+		t.Width = right - t.X
+		t.Height = bottom - t.Y
+		t.Text += " " + word.Text
+		t.Confidence = (t.Confidence*float64(words) + word.Confidence) / float64(words+1)
+		words++
+	}
+	return texts, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testTesseractTSV = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+	"1\t1\t0\t0\t0\t0\t0\t0\t1024\t768\t-1\t\n" +
+	"4\t1\t1\t1\t1\t0\t100\t50\t300\t20\t-1\t\n" +
+	"5\t1\t1\t1\t1\t1\t100\t50\t120\t20\t96.5\tSelect\n" +
+	"5\t1\t1\t1\t1\t2\t230\t52\t170\t18\t93.5\tLanguage\n" +
+	"5\t1\t2\t1\t1\t1\t800\t700\t100\t30\t90\tContinue\n"
+
+func TestParseTesseractTSV(t *testing.T) {
+	texts, err := parseTesseractTSV(testTesseractTSV)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []OCRText{
+		{Text: "Select Language", X: 100, Y: 50, Width: 300, Height: 20, Confidence: 0.95},
+		{Text: "Continue", X: 800, Y: 700, Width: 100, Height: 30, Confidence: 0.9},
+	}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Fatalf("bad: %#v", texts)
+	}
+
+	if _, err := parseTesseractTSV("header\n5\t1\t1\n"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestFindText(t *testing.T) {
+	texts := []OCRText{
+		{Text: "Select Language", X: 100, Y: 50, Width: 300, Height: 20},
+		{Text: "Continue", X: 800, Y: 700, Width: 100, Height: 30},
+	}
+
+	found, ok := findText(texts, "continue")
+	if !ok {
+		t.Fatal("should find the text")
+	}
+	if x, y := found.Center(); x != 850 || y != 715 {
+		t.Fatalf("bad center: %d,%d", x, y)
+	}
+
+	// The bounding box of a part of a line is estimated
+	found, ok = findText(texts, "Language")
+	if !ok {
+		t.Fatal("should find the text")
+	}
+	if found.Text != "Language" || found.X != 240 || found.Width != 160 {
+		t.Fatalf("bad: %#v", found)
+	}
+
+	if _, ok := findText(texts, "Back"); ok {
+		t.Fatal("should not find the text")
+	}
+	if _, ok := findText(texts, " "); ok {
+		t.Fatal("should not find empty text")
+	}
+}
+
+func TestLocateTexts(t *testing.T) {
+	texts := locateTexts([]OCRText{
+		{Text: "Select Language", X: 100, Y: 50, Width: 300, Height: 20, Confidence: 0.95},
+		{Text: "Continue", X: 800, Y: 700, Width: 100, Height: 30},
+	}, 1000, 1000)
+
+	expected := ScreenTexts{
+		{Text: "Select Language", X: 0.1, Y: 0.05, Width: 0.3, Height: 0.02, Confidence: 0.95, ScreenWidth: 1000, ScreenHeight: 1000},
+		{Text: "Continue", X: 0.8, Y: 0.7, Width: 0.1, Height: 0.03, ScreenWidth: 1000, ScreenHeight: 1000},
+	}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Fatalf("bad: %#v", texts)
+	}
+	if texts.String() != "Select Language Continue" {
+		t.Fatalf("bad text: %s", texts)
+	}
+}
+
+func TestScreenTextsFind(t *testing.T) {
+	texts := locateTexts([]OCRText{
+		{Text: "Select Language", X: 100, Y: 50, Width: 300, Height: 20},
+		{Text: "Continue", X: 800, Y: 700, Width: 100, Height: 30},
+	}, 1000, 1000)
+
+	found := texts.Find("continue")
+	if found.Text != "Continue" || found.CenterX() != 850 || found.CenterY() != 715 {
+		t.Fatalf("bad: %#v", found)
+	}
+
+	// The bounding box of a part of a line is estimated
+	found = texts.Find("Language")
+	if found.Text != "Language" || found.CenterX() != 320 || found.CenterY() != 60 {
+		t.Fatalf("bad: %#v", found)
+	}
+
+	if found := texts.Find("Back"); found.Text != "" {
+		t.Fatalf("should not find the text: %#v", found)
+	}
+}
@@ -6,17 +6,33 @@ package common
 import "errors"
 
 type OCRWrapper interface {
-	// IdentifyCurrentScreen takes a screenshot of the current screen and returns the BootScreenConfig that matches the screen.
-	IdentifyCurrentScreen(imagePath string) (bootScreenConfig BootScreenConfig, err error)
+	// IdentifyCurrentScreen takes a screenshot of the current screen and returns the BootScreenConfig that matches the screen,
+	// along with the texts recognized on it, if any.
+	IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error)
 	// Removes the screen config with specified name if exist
 	RemoveBootScreenConfigIfExist(screenName string)
+	// RecognizeText returns the lines of text recognized in the image, with their bounding boxes.
+	RecognizeText(imagePath string) ([]OCRText, error)
 }
 
-func NewOCRWrapper(OCRLibrary string, ScreenConfigs map[string]BootScreenConfig) (OCRWrapper, error) {
+// NewOCRWrapper returns the wrapper of the OCR library, identifying the
+// screens in the order of their priority, then of their declaration.
+func NewOCRWrapper(config OCRConfig, ScreenConfigs []BootScreenConfig) (OCRWrapper, error) {
+	OCRLibrary := config.OCRLibrary
+	if OCRLibrary == "" {
+		OCRLibrary = "vision"
+	}
+
 	if OCRLibrary == "vision" {
-		return NewVisionOCRWrapper(ScreenConfigs), nil
+		return NewVisionOCRWrapper(ScreenConfigs)
 	} else if OCRLibrary == "tesseract" {
-		return NewTesseractOCRWrapper(ScreenConfigs), nil
+		return NewTesseractOCRWrapper(ScreenConfigs)
+	} else if OCRLibrary == "image" {
+		return NewImageOCRWrapper(ScreenConfigs)
+	} else if OCRLibrary == "command" {
+		return NewBackendOCRWrapper(&CommandOCRBackend{Command: config.OCRCommand}, ScreenConfigs)
+	} else if OCRLibrary == "http" {
+		return NewBackendOCRWrapper(&HTTPOCRBackend{URL: config.OCRURL}, ScreenConfigs)
 	} else {
 		return nil, errors.New("invalid OCR library")
 	}
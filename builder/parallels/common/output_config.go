@@ -23,8 +23,24 @@ type OutputConfig struct {
 	// the builder. By default this is "output-BUILDNAME" where "BUILDNAME" is the
 	// name of the build.
 	OutputDir string `mapstructure:"output_directory" required:"false"`
+	// A list of glob patterns of files and directories which are not part of
+	// the artifact. They are moved out of the VM into the
+	// `<output_directory>-logs` directory next to the output directory at the
+	// end of the build, so that they are still available for troubleshooting.
+	// Patterns without a slash are matched against the file names, other
+	// patterns against the paths relative to the output directory, e.g.
+	// "*.pvm/Snapshots.xml". Defaults to
+	// `["*.log", "*.backup", "*.Backup", "*.app"]`.
+	ArtifactExclude []string `mapstructure:"artifact_exclude" required:"false"`
+	// A list of glob patterns of files and directories which are kept in the
+	// artifact even if they match artifact_exclude, e.g. "parallels.log".
+	ArtifactKeep []string `mapstructure:"artifact_keep" required:"false"`
 }
 
+// These are the files and directories that are unnecessary for the function
+// of a Parallels virtual machine.
+var defaultArtifactExclude = []string{"*.log", "*.backup", "*.Backup", "*.app"}
+
 // Prepare configures the output directory or returns an error if it already exists.
 func (c *OutputConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig) []error {
 	if c.OutputDir == "" {
@@ -43,6 +59,16 @@ func (c *OutputConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig
 		c.OutputDir = path.Clean(path.Join(wd, c.OutputDir))
 	}
 
+	if c.ArtifactExclude == nil {
+		c.ArtifactExclude = defaultArtifactExclude
+	}
+
+	for _, pattern := range append(c.ArtifactExclude, c.ArtifactKeep...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid artifact pattern %q: %s", pattern, err))
+		}
+	}
+
 	if !pc.PackerForce {
 		if _, err := os.Stat(c.OutputDir); err == nil {
 			errs = append(errs, fmt.Errorf(
@@ -71,3 +71,25 @@ func TestOutputConfigPrepare_forceExists(t *testing.T) {
 		t.Fatal("should not have errors")
 	}
 }
+
+func TestOutputConfigPrepare_artifactPatterns(t *testing.T) {
+	pc := &common.PackerConfig{PackerBuildName: "foo"}
+
+	// Test the defaults
+	c := new(OutputConfig)
+	errs := c.Prepare(interpolate.NewContext(), pc)
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if len(c.ArtifactExclude) == 0 {
+		t.Fatal("should have default exclude patterns")
+	}
+
+	// Test with a bad pattern
+	c = new(OutputConfig)
+	c.ArtifactKeep = []string{"[parallels.log"}
+	errs = c.Prepare(interpolate.NewContext(), pc)
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+// The mouse buttons and actions supported by SendMouseEvent.
+const (
+	MouseButtonNone   = ""
+	MouseButtonLeft   = "left"
+	MouseButtonRight  = "right"
+	MouseButtonMiddle = "middle"
+
+	MouseActionMove    = "move"
+	MouseActionPress   = "press"
+	MouseActionRelease = "release"
+	MouseActionClick   = "click"
+)
+
+// mouseButtonMasks are the masks of the mouse buttons in the Parallels
+// Virtualization SDK.
+var mouseButtonMasks = map[string]int{
+	MouseButtonNone:   0,
+	MouseButtonLeft:   1,
+	MouseButtonRight:  2,
+	MouseButtonMiddle: 4,
+}
+
+// Prlmouse is a Python script that moves the mouse pointer of a Parallels VM
+// to absolute coordinates and presses or releases its buttons. It requires
+// the prlsdkapi Python module, which is bundled with the Parallels
+// Virtualization SDK.
+const Prlmouse string = prlsdkBootstrap + `
+import time
+
+
+def main():
+    if len(sys.argv) != 6:
+        print("Usage: prlmouse VM_NAME X Y BUTTONS move|press|release|click")
+        sys.exit(1)
+
+    vm_name = sys.argv[1]
+    x, y, buttons = int(sys.argv[2]), int(sys.argv[3]), int(sys.argv[4])
+    action = sys.argv[5]
+
+    server = login()
+    vm, vm_io = connect(server, vm_name)
+
+    send(vm, vm_io, x, y, buttons, action)
+
+    disconnect(server, vm, vm_io)
+
+
+def send(vm, vm_io, x, y, buttons, action):
+    delay = 0.1
+
+    # The pointer is moved first, so that the button events happen in place.
+    # The buttons to release are held while moving, which allows dragging.
+    held = buttons if action == "release" else 0
+    vm_io.mouse_set_pos(vm, x, y, 0, held)
+    time.sleep(delay)
+
+    if action in ("press", "click"):
+        vm_io.mouse_set_pos(vm, x, y, 0, buttons)
+        time.sleep(delay)
+    if action in ("release", "click"):
+        vm_io.mouse_set_pos(vm, x, y, 0, 0)
+        time.sleep(delay)
+
+
+if __name__ == "__main__":
+    main()
+`
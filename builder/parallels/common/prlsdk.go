@@ -0,0 +1,69 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"os/exec"
+)
+
+// prlsdkPythonPath is the directory of the prlsdkapi Python module, bundled
+// with the Parallels Virtualization SDK.
+const prlsdkPythonPath = "/Library/Frameworks/ParallelsVirtualizationSDK.framework/Versions/Current/Libraries/Python/3.7"
+
+// prlsdkBootstrap is the Python code shared by the scripts built on the
+// Parallels Virtualization SDK: it logs in to the local Parallels service,
+// connects to the VM by its name and disconnects from it.
+const prlsdkBootstrap string = `
+import sys
+import prlsdkapi
+
+
+def login():
+    prlsdkapi.prlsdk.InitializeSDK(prlsdkapi.prlsdk.consts.PAM_DESKTOP_MAC)
+    server = prlsdkapi.Server()
+    login_job = server.login_local()
+    login_job.wait()
+
+    return server
+
+
+def connect(server, vm_name):
+    vm_list_job = server.get_vm_list()
+    result = vm_list_job.wait()
+
+    vm_list = [result.get_param_by_index(i) for i in range(result.get_params_count())]
+    vm = [vm for vm in vm_list if vm.get_name() == vm_name]
+
+    if not vm:
+        vm_names = [vm.get_name() for vm in vm_list]
+        raise Exception(
+            "%s: No such VM. Available VM's are:\n%s" % (vm_name, "\n".join(vm_names))
+        )
+
+    vm = vm[0]
+
+    vm_io = prlsdkapi.VmIO()
+    vm_io.connect_to_vm(vm).wait()
+
+    return (vm, vm_io)
+
+
+def disconnect(server, vm, vm_io):
+    if vm and vm_io:
+        vm_io.disconnect_from_vm(vm)
+
+    if server:
+        server.logoff()
+
+    prlsdkapi.deinit_sdk()
+`
+
+// prlsdkPython returns the command running Python with the arguments, the
+// prlsdkapi module being importable.
+func prlsdkPython(args ...string) *exec.Cmd {
+	cmd := exec.Command("/usr/bin/python3", args...)
+	cmd.Env = append(os.Environ(), "PYTHONPATH="+os.Getenv("PYTHONPATH")+":"+prlsdkPythonPath)
+	return cmd
+}
@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// RegistrationConfig contains the configuration for keeping the resulting
+// VM registered in Parallels Desktop.
+type RegistrationConfig struct {
+	// If true, the VM is left registered in Parallels Desktop at the end of a
+	// successful build, so that it can be used right away. Failed builds
+	// always unregister the VM. Defaults to false.
+	KeepRegistered bool `mapstructure:"keep_registered" required:"false"`
+	// If true, the VM is converted to a template at the end of the build and
+	// left registered in Parallels Desktop. Implies keep_registered.
+	// Defaults to false.
+	RegisterAsTemplate bool `mapstructure:"register_as_template" required:"false"`
+}
+
+// Prepare sets the implied options.
+func (c *RegistrationConfig) Prepare(ctx *interpolate.Context) []error {
+	if c.RegisterAsTemplate {
+		c.KeepRegistered = true
+	}
+	return nil
+}
+
+// KeepVMRegistered reports whether the VM has to be left registered when the
+// steps are cleaned up, which is only the case for successful builds.
+func KeepVMRegistered(state multistep.StateBag, keepRegistered bool) bool {
+	if !keepRegistered {
+		return false
+	}
+	if _, ok := state.GetOk("error"); ok {
+		return false
+	}
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return false
+	}
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return false
+	}
+	return true
+}
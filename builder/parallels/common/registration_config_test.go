@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestRegistrationConfigPrepare(t *testing.T) {
+	c := new(RegistrationConfig)
+	c.RegisterAsTemplate = true
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	if !c.KeepRegistered {
+		t.Fatal("templates should be kept registered")
+	}
+}
+
+func TestKeepVMRegistered(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if KeepVMRegistered(state, false) {
+		t.Fatal("should not keep the VM")
+	}
+	if !KeepVMRegistered(state, true) {
+		t.Fatal("should keep the VM")
+	}
+
+	state.Put("error", errors.New("failed"))
+	if KeepVMRegistered(state, true) {
+		t.Fatal("should not keep the VM of a failed build")
+	}
+
+	state = new(multistep.BasicStateBag)
+	state.Put(multistep.StateCancelled, true)
+	if KeepVMRegistered(state, true) {
+		t.Fatal("should not keep the VM of a cancelled build")
+	}
+}
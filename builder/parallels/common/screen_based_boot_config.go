@@ -0,0 +1,99 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// ScreenBasedBootConfig contains the configuration of the screen based boot,
+// run after the `boot_command`: the screens of the VM are identified with the
+// OCR library, and the boot command of each screen is typed once it shows
+// up. The screen of the VM is captured with `prlctl capture`, or from the
+// window of macOS VMs prior to Parallels Desktop 20.
+type ScreenBasedBootConfig struct {
+	// Screens and it's boot configs
+	// A screen is considered matched if all the matching strings are present in the screen.
+	// The first matching screen will be considered & boot config of that screen will be used.
+	// If matching strings are empty, then it is considered as empty screen,
+	// empty screen has some special meaning, which will be considered when none of the other screens are matched.
+	// You can use this screen to make system wait for some time / execute a common boot command etc.
+	// The empty screen boot command will be executed repeatedly until a non-empty screen is found.
+	// If more than one empty screen is found, then it is considered as an error.
+	BootScreenConfig BootScreensConfig `mapstructure:"boot_screen_config" required:"false"`
+	// The maximum duration of the screen based boot, until the last screen
+	// is identified, e.g. "1h". The build fails on timeout, and the last
+	// screenshot and its text are saved. Defaults to 0, no timeout.
+	BootScreenTimeout time.Duration `mapstructure:"boot_screen_timeout" required:"false"`
+	// The directory keeping every distinct screenshot of the screen based
+	// boot, deduplicated by the hash of the image. Each screenshot has a
+	// JSON sidecar with the timestamp, the recognized text, the identified
+	// screen and the boot command sent. The screenshots are also assembled
+	// into an animated GIF, `boot.gif`. By default, no screenshot is kept.
+	BootScreenshotsDir string `mapstructure:"boot_screenshots_dir" required:"false"`
+	// The HCL file the screens are recorded to, instead of running the
+	// screen based boot. The operator drives the VM in its window, then
+	// presses enter. The captures are clustered into distinct screens, and
+	// a `boot_screen_config` is proposed for each, matching the words on
+	// this screen only, to edit before pasting into the template.
+	BootScreenRecord string `mapstructure:"boot_screen_record" required:"false"`
+}
+
+// Prepare validates the screens, identified by the OCR library, which must
+// be prepared first.
+func (c *ScreenBasedBootConfig) Prepare(ctx *interpolate.Context, ocr *OCRConfig) (errs []error) {
+	emptyScreenCount := 0
+	screenNames := make(map[string]bool)
+	for i := range c.BootScreenConfig {
+		screenConfig := &c.BootScreenConfig[i]
+		errs = append(errs, screenConfig.Prepare(ctx)...)
+		if screenConfig.IsEmpty() {
+			emptyScreenCount++
+		}
+
+		if screenConfig.ScreenName == "" {
+			continue
+		}
+
+		if screenNames[screenConfig.ScreenName] {
+			errs = append(errs, fmt.Errorf("multiple screens with same name: %s", screenConfig.ScreenName))
+			continue
+		}
+		screenNames[screenConfig.ScreenName] = true
+	}
+
+	if graph := newScreenGraph(c.BootScreenConfig); graph != nil {
+		errs = append(errs, graph.validate()...)
+	}
+
+	if c.BootScreenTimeout < 0 {
+		errs = append(errs, fmt.Errorf("boot_screen_timeout must not be negative"))
+	}
+
+	if emptyScreenCount > 1 {
+		errs = append(errs, fmt.Errorf("more than one empty screen config found."+
+			"only one empty screen config is allowed"))
+	}
+
+	if ocr.OCRLibrary == "image" {
+		for _, screenConfig := range c.BootScreenConfig {
+			if !screenConfig.IsEmpty() && screenConfig.MatchImage == "" {
+				errs = append(errs, fmt.Errorf("screen %s has no match_image, required by the image ocr_library", screenConfig.ScreenName))
+			}
+			if len(screenConfig.Region) > 0 {
+				errs = append(errs, fmt.Errorf("screen %s has a region, not supported by the image ocr_library, use match_region", screenConfig.ScreenName))
+			}
+		}
+		if c.BootScreenRecord != "" {
+			errs = append(errs, fmt.Errorf("boot_screen_record requires an ocr_library recognizing text"))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,185 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// screenGraph is the graph of the transitions between the screens, declared
+// by their next_screens. The empty and failure screens aren't part of it, as
+// they may show up anytime.
+type screenGraph struct {
+	screens []BootScreenConfig
+	// The screen the screen based boot starts on
+	start string
+	// The next screens of each screen, any screen if empty
+	next map[string][]string
+	// The last screens, ending the screen based boot
+	last map[string]bool
+}
+
+// newScreenGraph returns the graph of the screens, or nil if none of them
+// declares its next screens.
+func newScreenGraph(screens []BootScreenConfig) *screenGraph {
+	declared := false
+	for _, screen := range screens {
+		if len(screen.NextScreens) > 0 {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return nil
+	}
+
+	g := &screenGraph{next: make(map[string][]string), last: make(map[string]bool)}
+	for _, screen := range screens {
+		if !inScreenGraph(screen) {
+			continue
+		}
+		if g.start == "" {
+			g.start = screen.ScreenName
+		}
+		g.screens = append(g.screens, screen)
+		g.next[screen.ScreenName] = screen.NextScreens
+		g.last[screen.ScreenName] = screen.IsLastScreen
+	}
+	return g
+}
+
+func inScreenGraph(screen BootScreenConfig) bool {
+	return screen.ScreenName != "" && !screen.IsEmpty() && !screen.IsFailureScreen
+}
+
+// allowed returns whether the screen may follow the other one, the start
+// screen only following none.
+func (g *screenGraph) allowed(from, to string) bool {
+	if from == "" {
+		return to == g.start
+	}
+	next := g.next[from]
+	if len(next) == 0 {
+		return true
+	}
+	for _, name := range next {
+		if name == to {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the next screens exist, and that every screen is
+// reachable from the start screen and leads to a last screen.
+func (g *screenGraph) validate() (errs []error) {
+	for _, screen := range g.screens {
+		for _, name := range screen.NextScreens {
+			if _, ok := g.next[name]; !ok {
+				errs = append(errs, fmt.Errorf("next_screens of screen %q: no screen %q, other than the empty and failure screens",
+					screen.ScreenName, name))
+			}
+		}
+	}
+
+	// The screens reachable from the start screen, the boot ending on the
+	// last screens
+	reachable := map[string]bool{g.start: true}
+	queue := []string{g.start}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		if g.last[from] {
+			continue
+		}
+		for _, screen := range g.screens {
+			if !reachable[screen.ScreenName] && g.allowed(from, screen.ScreenName) {
+				reachable[screen.ScreenName] = true
+				queue = append(queue, screen.ScreenName)
+			}
+		}
+	}
+
+	// The screens leading to a last screen
+	leading := make(map[string]bool)
+	for name, last := range g.last {
+		if last {
+			leading[name] = true
+		}
+	}
+	if len(leading) == 0 {
+		return append(errs, fmt.Errorf("next_screens requires a screen with is_last_screen"))
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, from := range g.screens {
+			if leading[from.ScreenName] {
+				continue
+			}
+			for _, to := range g.screens {
+				if leading[to.ScreenName] && g.allowed(from.ScreenName, to.ScreenName) {
+					leading[from.ScreenName] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, screen := range g.screens {
+		if !reachable[screen.ScreenName] {
+			errs = append(errs, fmt.Errorf("screen %q is unreachable from the first screen %q", screen.ScreenName, g.start))
+		} else if !leading[screen.ScreenName] {
+			errs = append(errs, fmt.Errorf("no path from screen %q to a last screen", screen.ScreenName))
+		}
+	}
+	return errs
+}
+
+// screenPath is the path of the screen based boot through the screens,
+// checking the transitions against the graph, if any, and the visits of the
+// screens against their max_visits.
+type screenPath struct {
+	graph  *screenGraph
+	names  []string
+	visits map[string]int
+}
+
+func newScreenPath(graph *screenGraph) *screenPath {
+	return &screenPath{graph: graph, visits: make(map[string]int)}
+}
+
+// visit records the screen identified, and returns an error on an unexpected
+// transition or on too many visits of the screen.
+func (p *screenPath) visit(screen BootScreenConfig) error {
+	if !inScreenGraph(screen) {
+		return nil
+	}
+
+	last := ""
+	if len(p.names) > 0 {
+		last = p.names[len(p.names)-1]
+	}
+	if screen.ScreenName == last {
+		return nil
+	}
+
+	p.names = append(p.names, screen.ScreenName)
+	p.visits[screen.ScreenName]++
+	if p.graph != nil && !p.graph.allowed(last, screen.ScreenName) {
+		if last == "" {
+			return fmt.Errorf("unexpected first screen %q instead of %q, visited screens: %s", screen.ScreenName, p.graph.start, p)
+		}
+		return fmt.Errorf("unexpected transition from screen %q to %q, visited screens: %s", last, screen.ScreenName, p)
+	}
+	if screen.MaxVisits > 0 && p.visits[screen.ScreenName] > screen.MaxVisits {
+		return fmt.Errorf("screen %q visited more than %d times, visited screens: %s", screen.ScreenName, screen.MaxVisits, p)
+	}
+	return nil
+}
+
+func (p *screenPath) String() string {
+	return strings.Join(p.names, " -> ")
+}
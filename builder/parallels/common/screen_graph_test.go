@@ -0,0 +1,167 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func testScreenGraphScreens() []BootScreenConfig {
+	return []BootScreenConfig{
+		{ScreenName: "wait"},
+		{ScreenName: "error", MatchingStrings: []string{"error"}, IsFailureScreen: true},
+		{ScreenName: "language", MatchingStrings: []string{"language"}, NextScreens: []string{"install"}},
+		{ScreenName: "install", MatchingStrings: []string{"install"}, NextScreens: []string{"retry", "done"}},
+		{ScreenName: "retry", MatchingStrings: []string{"retry"}, NextScreens: []string{"install"}, MaxVisits: 2},
+		{ScreenName: "done", MatchingStrings: []string{"done"}, IsLastScreen: true},
+	}
+}
+
+func TestNewScreenGraph(t *testing.T) {
+	if g := newScreenGraph([]BootScreenConfig{{ScreenName: "language", MatchingStrings: []string{"language"}}}); g != nil {
+		t.Fatalf("should not have a graph: %#v", g)
+	}
+
+	g := newScreenGraph(testScreenGraphScreens())
+	if g.start != "language" {
+		t.Fatalf("bad start screen: %s", g.start)
+	}
+	if len(g.screens) != 4 {
+		t.Fatalf("bad screens: %#v", g.screens)
+	}
+	if errs := g.validate(); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{"", "language", true},
+		{"", "install", false},
+		{"language", "install", true},
+		{"language", "done", false},
+		{"retry", "install", true},
+		{"done", "language", true},
+	}
+	for _, c := range cases {
+		if allowed := g.allowed(c.from, c.to); allowed != c.allowed {
+			t.Errorf("%q to %q: bad allowed: %t", c.from, c.to, allowed)
+		}
+	}
+}
+
+func TestScreenGraphValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		screens func([]BootScreenConfig) []BootScreenConfig
+		err     string
+	}{
+		{
+			"unknown next screen",
+			func(screens []BootScreenConfig) []BootScreenConfig {
+				screens[2].NextScreens = []string{"instal"}
+				return screens
+			},
+			`next_screens of screen "language": no screen "instal"`,
+		},
+		{
+			"failure next screen",
+			func(screens []BootScreenConfig) []BootScreenConfig {
+				screens[3].NextScreens = []string{"error", "done"}
+				return screens
+			},
+			`next_screens of screen "install": no screen "error"`,
+		},
+		{
+			"unreachable screen",
+			func(screens []BootScreenConfig) []BootScreenConfig {
+				screens[3].NextScreens = []string{"done"}
+				return screens
+			},
+			`screen "retry" is unreachable from the first screen "language"`,
+		},
+		{
+			"no path to the last screen",
+			func(screens []BootScreenConfig) []BootScreenConfig {
+				screens[3].NextScreens = []string{"retry"}
+				return screens
+			},
+			`no path from screen "language" to a last screen`,
+		},
+		{
+			"no last screen",
+			func(screens []BootScreenConfig) []BootScreenConfig {
+				screens[5].IsLastScreen = false
+				return screens
+			},
+			"next_screens requires a screen with is_last_screen",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			errs := newScreenGraph(c.screens(testScreenGraphScreens())).validate()
+			for _, err := range errs {
+				if strings.Contains(err.Error(), c.err) {
+					return
+				}
+			}
+			t.Fatalf("should have error %q: %v", c.err, errs)
+		})
+	}
+}
+
+func TestScreenPathVisit(t *testing.T) {
+	screens := make(map[string]BootScreenConfig)
+	for _, screen := range testScreenGraphScreens() {
+		screens[screen.ScreenName] = screen
+	}
+
+	path := newScreenPath(newScreenGraph(testScreenGraphScreens()))
+	for _, name := range []string{"wait", "language", "wait", "language", "install", "retry", "install", "error", "retry", "install"} {
+		if err := path.visit(screens[name]); err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+	}
+	if path.String() != "language -> install -> retry -> install -> retry -> install" {
+		t.Fatalf("bad path: %s", path)
+	}
+
+	err := path.visit(screens["retry"])
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	expected := `screen "retry" visited more than 2 times, visited screens: ` +
+		"language -> install -> retry -> install -> retry -> install -> retry"
+	if err.Error() != expected {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	path = newScreenPath(newScreenGraph(testScreenGraphScreens()))
+	if err := path.visit(screens["language"]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = path.visit(screens["done"])
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	expected = `unexpected transition from screen "language" to "done", visited screens: language -> done`
+	if err.Error() != expected {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	path = newScreenPath(newScreenGraph(testScreenGraphScreens()))
+	if err := path.visit(screens["install"]); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Without next screens, only the visits are limited
+	path = newScreenPath(nil)
+	for _, name := range []string{"install", "done", "language"} {
+		if err := path.visit(screens[name]); err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+	}
+}
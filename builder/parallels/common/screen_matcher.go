@@ -0,0 +1,258 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ocrConfusions maps the characters OCR commonly confuses to a single one,
+// so that e.g. "Se1ect" and "select" match.
+var ocrConfusions = strings.NewReplacer(
+	"1", "l",
+	"i", "l",
+	"|", "l",
+	"0", "o",
+)
+
+// normalizeOCRText lowercases the text and, for fuzzy matching, normalizes
+// the characters OCR commonly confuses.
+func normalizeOCRText(text string, fuzzy bool) string {
+	text = strings.ToLower(text)
+	if !fuzzy {
+		return text
+	}
+	return ocrConfusions.Replace(text)
+}
+
+// screenMatcher identifies the screen from the text recognized on it. The
+// screens are tried by descending priority, then in the order of their
+// declaration, so that the first matching screen wins. The empty screen is
+// identified when no other screen matches.
+type screenMatcher struct {
+	screens []matcherScreen
+}
+
+type matcherScreen struct {
+	config BootScreenConfig
+	regex  *regexp.Regexp
+	// The normalized matching and exclude strings, in the order of the
+	// configuration
+	matchingStrings []string
+	excludeStrings  []string
+}
+
+func newScreenMatcher(screens []BootScreenConfig) (*screenMatcher, error) {
+	m := &screenMatcher{}
+	for _, screen := range screens {
+		s := matcherScreen{config: screen}
+		fuzzy := screen.FuzzyDistance > 0
+		if screen.MatchingRegex != "" {
+			regex, err := compileMatchingRegex(screen.MatchingRegex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid matching_regex of screen %q: %s", screen.ScreenName, err)
+			}
+			s.regex = regex
+		}
+		for _, matchingString := range screen.MatchingStrings {
+			s.matchingStrings = append(s.matchingStrings, normalizeOCRText(matchingString, fuzzy))
+		}
+		for _, excludeString := range screen.ExcludeStrings {
+			s.excludeStrings = append(s.excludeStrings, normalizeOCRText(excludeString, fuzzy))
+		}
+		m.screens = append(m.screens, s)
+	}
+
+	sort.SliceStable(m.screens, func(i, j int) bool {
+		return m.screens[i].config.Priority > m.screens[j].config.Priority
+	})
+	return m, nil
+}
+
+// match returns the screen identified by the text, and false if no screen
+// matches and there is no empty screen.
+func (m *screenMatcher) match(text string) (BootScreenConfig, bool) {
+	return m.matchWords(ScreenTexts{{Text: text}})
+}
+
+// matchWords returns the screen identified by the recognized words. The
+// words less confident than the min_confidence of a screen, or out of its
+// region, are ignored for it.
+func (m *screenMatcher) matchWords(texts ScreenTexts) (BootScreenConfig, bool) {
+	config, ok, _ := m.find(func(s *matcherScreen) (bool, error) {
+		return s.mismatch(s.wordsText(texts), false) == "", nil
+	})
+	return config, ok
+}
+
+// logNearMisses logs why each screen, other than the empty screen, doesn't
+// match the recognized words, along with the closest text to the strings
+// not found. This helps to tune the screen configurations.
+func (m *screenMatcher) logNearMisses(texts ScreenTexts) {
+	for i := range m.screens {
+		s := &m.screens[i]
+		if s.config.IsEmpty() {
+			continue
+		}
+		if reason := s.mismatch(s.wordsText(texts), true); reason != "" {
+			log.Printf("Screen %q not matched: %s", s.config.ScreenName, reason)
+		}
+	}
+}
+
+// find returns the first screen, other than the empty screen, for which
+// matches returns true, or else the empty screen. It returns false if no
+// screen matches and there is no empty screen, and stops at the first error
+// of matches.
+func (m *screenMatcher) find(matches func(*matcherScreen) (bool, error)) (BootScreenConfig, bool, error) {
+	var empty *BootScreenConfig
+	for i := range m.screens {
+		s := &m.screens[i]
+		if s.config.IsEmpty() {
+			if empty == nil {
+				empty = &s.config
+			}
+			continue
+		}
+		ok, err := matches(s)
+		if err != nil {
+			return BootScreenConfig{}, false, err
+		}
+		if ok {
+			return s.config, true, nil
+		}
+	}
+
+	if empty != nil {
+		return *empty, true, nil
+	}
+	return BootScreenConfig{}, false, nil
+}
+
+// remove removes the screen with the name, if any.
+func (m *screenMatcher) remove(screenName string) {
+	for i, s := range m.screens {
+		if s.config.ScreenName == screenName {
+			m.screens = append(m.screens[:i], m.screens[i+1:]...)
+			return
+		}
+	}
+}
+
+// mismatch returns why the screen doesn't match the text, or an empty string
+// if it matches. Unless explain is true, the closest text to the strings not
+// found isn't searched, which is much faster.
+func (s *matcherScreen) mismatch(text string, explain bool) string {
+	normalized := normalizeOCRText(text, s.config.FuzzyDistance > 0)
+	for i, excludeString := range s.excludeStrings {
+		if strings.Contains(normalized, excludeString) {
+			return fmt.Sprintf("%q is excluded", s.config.ExcludeStrings[i])
+		}
+	}
+
+	if s.regex != nil && !s.regex.MatchString(strings.ToLower(text)) {
+		return fmt.Sprintf("matching_regex %q doesn't match", s.config.MatchingRegex)
+	}
+
+	var misses []string
+	bestMiss := ""
+	bestDistance := -1
+	for i, matchingString := range s.matchingStrings {
+		distance, closest := 0, matchingString
+		if !strings.Contains(normalized, matchingString) {
+			if s.config.FuzzyDistance == 0 && !explain {
+				if s.config.MatchAny {
+					continue
+				}
+				return "not found"
+			}
+			distance, closest = fuzzyFind(normalized, matchingString)
+		}
+		if distance <= s.config.FuzzyDistance {
+			if s.config.MatchAny {
+				return ""
+			}
+			continue
+		}
+
+		miss := fmt.Sprintf("%q not found, the closest text is %q at distance %d", s.config.MatchingStrings[i], closest, distance)
+		misses = append(misses, miss)
+		if bestDistance < 0 || distance < bestDistance {
+			bestMiss, bestDistance = miss, distance
+		}
+	}
+
+	// With match_any, no matching string was found if any
+	if s.config.MatchAny && len(s.matchingStrings) > 0 {
+		if bestMiss == "" {
+			return "none of the matching strings found"
+		}
+		return bestMiss
+	}
+	return strings.Join(misses, ", ")
+}
+
+// wordsText joins the words at least as confident as the min_confidence of
+// the screen, and in its region if any. Words without confidence are kept.
+func (s *matcherScreen) wordsText(texts ScreenTexts) string {
+	var words []string
+	for _, text := range texts {
+		if text.Confidence > 0 && text.Confidence < s.config.MinConfidence {
+			continue
+		}
+		if len(s.config.Region) == 4 && !text.in(s.config.Region) {
+			continue
+		}
+		words = append(words, text.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+// fuzzyFind returns the smallest edit distance between the pattern and any
+// substring of the text, and that substring.
+func fuzzyFind(text, pattern string) (int, string) {
+	t, p := []rune(text), []rune(pattern)
+
+	// The edit distance of the pattern's prefix ending at the substring of
+	// the text from start to the column
+	type cell struct {
+		distance int
+		start    int
+	}
+	prev := make([]cell, len(t)+1)
+	cur := make([]cell, len(t)+1)
+	for j := range prev {
+		prev[j] = cell{0, j}
+	}
+
+	for i := 1; i <= len(p); i++ {
+		cur[0] = cell{i, 0}
+		for j := 1; j <= len(t); j++ {
+			best := cell{prev[j-1].distance, prev[j-1].start}
+			if p[i-1] != t[j-1] {
+				best.distance++
+			}
+			if d := prev[j].distance + 1; d < best.distance {
+				best = cell{d, prev[j].start}
+			}
+			if d := cur[j-1].distance + 1; d < best.distance {
+				best = cell{d, cur[j-1].start}
+			}
+			cur[j] = best
+		}
+		prev, cur = cur, prev
+	}
+
+	end := 0
+	for j := range prev {
+		if prev[j].distance < prev[end].distance {
+			end = j
+		}
+	}
+	return prev[end].distance, string(t[prev[end].start:end])
+}
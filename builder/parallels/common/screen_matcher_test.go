@@ -0,0 +1,256 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+)
+
+func TestScreenMatcher_match(t *testing.T) {
+	screens := []BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "language", MatchingStrings: []string{"select", "language"}},
+		{ScreenName: "language-or-region", MatchingStrings: []string{"language", "region"}, MatchAny: true},
+		{ScreenName: "keyboard", MatchingStrings: []string{"keyboard"}, ExcludeStrings: []string{"language"}},
+		{ScreenName: "version", MatchingRegex: `macos \d+`},
+	}
+	m, err := newScreenMatcher(screens)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]string{
+		"Select your Language":          "language",
+		"Select a Region":               "language-or-region",
+		"keyboard layout":               "keyboard",
+		"keyboard and input language":   "language-or-region",
+		"Welcome to macOS 14":           "version",
+		"Welcome to macOS":              "empty",
+		"":                              "empty",
+		"Language: select the keyboard": "language",
+	}
+	for text, expected := range cases {
+		screen, ok := m.match(text)
+		if !ok {
+			t.Errorf("%q: should match", text)
+			continue
+		}
+		if screen.ScreenName != expected {
+			t.Errorf("%q: bad screen: %s, expected: %s", text, screen.ScreenName, expected)
+		}
+	}
+}
+
+func TestScreenMatcher_order(t *testing.T) {
+	screens := []BootScreenConfig{
+		{ScreenName: "first", MatchingStrings: []string{"continue"}},
+		{ScreenName: "second", MatchingStrings: []string{"continue"}},
+	}
+
+	// The first declared screen wins, every time
+	for i := 0; i < 20; i++ {
+		m, err := newScreenMatcher(screens)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if screen, _ := m.match("Continue"); screen.ScreenName != "first" {
+			t.Fatalf("bad screen: %s", screen.ScreenName)
+		}
+	}
+
+	// Unless another screen has a higher priority
+	screens[1].Priority = 1
+	m, err := newScreenMatcher(screens)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if screen, _ := m.match("Continue"); screen.ScreenName != "second" {
+		t.Fatalf("bad screen: %s", screen.ScreenName)
+	}
+
+	m.remove("second")
+	if screen, _ := m.match("Continue"); screen.ScreenName != "first" {
+		t.Fatalf("bad screen: %s", screen.ScreenName)
+	}
+}
+
+func TestScreenMatcher_noEmptyScreen(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "language", MatchingStrings: []string{"language"}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := m.match("Welcome"); ok {
+		t.Fatal("should not match")
+	}
+}
+
+func TestScreenMatcher_invalidRegex(t *testing.T) {
+	_, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "bad", MatchingRegex: `macos (`},
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestScreenMatcher_fuzzy(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "continue", MatchingStrings: []string{"continue"}, FuzzyDistance: 1},
+		{ScreenName: "select", MatchingStrings: []string{"select your language"}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]string{
+		// The OCR confusions are normalized with a fuzzy distance
+		"C0ntlnue":             "continue",
+		"Se1ect your Language": "empty",
+		"SELECT Y0UR LANGUAGE": "empty",
+		// Within the fuzzy distance
+		"Contnue":   "continue",
+		"Continnue": "continue",
+		"Cotnue":    "empty",
+		// Exact match without fuzzy distance
+		"Selectyour language": "empty",
+	}
+	for text, expected := range cases {
+		screen, _ := m.match(text)
+		if screen.ScreenName != expected {
+			t.Errorf("%q: bad screen: %s, expected: %s", text, screen.ScreenName, expected)
+		}
+	}
+}
+
+func TestScreenMatcher_exact(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "install", MatchingStrings: []string{"install"}},
+		{ScreenName: "timeout", MatchingStrings: []string{"10 seconds"}, ExcludeStrings: []string{"cancel"}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Without fuzzy distance, the characters OCR commonly confuses are
+	// distinct
+	cases := map[string]string{
+		"Install":            "install",
+		"lnstall":            "empty",
+		"1nstall":            "empty",
+		"10 seconds":         "timeout",
+		"lo seconds":         "empty",
+		"10 seconds, Cance1": "timeout",
+		"10 seconds, Cancel": "empty",
+	}
+	for text, expected := range cases {
+		screen, _ := m.match(text)
+		if screen.ScreenName != expected {
+			t.Errorf("%q: bad screen: %s, expected: %s", text, screen.ScreenName, expected)
+		}
+	}
+}
+
+func TestScreenMatcher_minConfidence(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "continue", MatchingStrings: []string{"continue"}, MinConfidence: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		texts    ScreenTexts
+		expected string
+	}{
+		{ScreenTexts{{Text: "Continue", Confidence: 0.9}}, "continue"},
+		{ScreenTexts{{Text: "Continue", Confidence: 0.3}}, "empty"},
+		// Words without confidence are kept
+		{ScreenTexts{{Text: "Continue"}}, "continue"},
+	}
+	for _, c := range cases {
+		screen, _ := m.matchWords(c.texts)
+		if screen.ScreenName != c.expected {
+			t.Errorf("%#v: bad screen: %s, expected: %s", c.texts, screen.ScreenName, c.expected)
+		}
+	}
+}
+
+func TestScreenMatcher_mismatch(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "continue", MatchingStrings: []string{"continue", "agree"}},
+		{ScreenName: "any", MatchingStrings: []string{"continue", "agree"}, MatchAny: true},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	reason := m.screens[0].mismatch("Please Cotnue", true)
+	expected := `"continue" not found, the closest text is "cotnue" at distance 2, "agree" not found, the closest text is "ase" at distance 3`
+	if reason != expected {
+		t.Fatalf("bad: %s", reason)
+	}
+
+	if reason := m.screens[1].mismatch("Cotnue", true); reason != `"continue" not found, the closest text is "cotnue" at distance 2` {
+		t.Fatalf("bad: %s", reason)
+	}
+	if reason := m.screens[1].mismatch("Cotnue", false); reason == "" {
+		t.Fatal("should not match")
+	}
+	if reason := m.screens[1].mismatch("I agree", false); reason != "" {
+		t.Fatalf("should match: %s", reason)
+	}
+}
+
+func TestFuzzyFind(t *testing.T) {
+	cases := []struct {
+		text, pattern string
+		distance      int
+		closest       string
+	}{
+		{"click continue to go on", "continue", 0, "continue"},
+		{"click contnue to go on", "continue", 1, "contnue"},
+		{"click coontinue", "continue", 1, "oontinue"},
+		{"", "continue", 8, ""},
+		{"continue", "", 0, ""},
+	}
+	for _, c := range cases {
+		distance, closest := fuzzyFind(c.text, c.pattern)
+		if distance != c.distance || closest != c.closest {
+			t.Errorf("%q in %q: bad: %d, %q", c.pattern, c.text, distance, closest)
+		}
+	}
+}
+
+func TestScreenMatcher_region(t *testing.T) {
+	m, err := newScreenMatcher([]BootScreenConfig{
+		{ScreenName: "empty"},
+		{ScreenName: "continue", MatchingStrings: []string{"continue"}, Region: []float64{0.25, 0, 0.75, 1}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		texts    ScreenTexts
+		expected string
+	}{
+		// In the main pane
+		{ScreenTexts{{Text: "Continue", X: 0.8, Y: 0.9, Width: 0.1, Height: 0.05}}, "continue"},
+		// In the sidebar
+		{ScreenTexts{{Text: "Continue", X: 0.05, Y: 0.5, Width: 0.1, Height: 0.05}}, "empty"},
+		// Without location
+		{ScreenTexts{{Text: "Continue"}}, "empty"},
+	}
+	for _, c := range cases {
+		screen, _ := m.matchWords(c.texts)
+		if screen.ScreenName != c.expected {
+			t.Errorf("%#v: bad screen: %s, expected: %s", c.texts, screen.ScreenName, c.expected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"time"
+)
+
+const (
+	// The polling interval of the screen right after a boot command is
+	// typed, as the screen is about to change.
+	minPollInterval = 250 * time.Millisecond
+	// The polling interval of the screen once it changed.
+	defaultPollInterval = 1 * time.Second
+	// The polling interval of the screen once it has been unchanged for a
+	// while.
+	maxPollInterval = 5 * time.Second
+	// The OCR runs at least this often, in case a change of the screen is
+	// too small for the perceptual hash.
+	maxOCRInterval = 30 * time.Second
+)
+
+const (
+	// The maximum difference, out of 255, between the luminances of a cell
+	// of two screenshots of the same screen.
+	screenHashMargin = 16
+	// The number of cells of two screenshots of the same screen allowed to
+	// differ by more than the margin, e.g. because of a blinking cursor or
+	// a clock.
+	screenHashTolerance = 4
+)
+
+// screenHash is a perceptual hash of a screenshot: the average luminances of
+// the cells of a grid, from 0 to 255. Unlike a cryptographic hash, the hashes
+// of two screenshots are compared by how much they differ.
+type screenHash []uint8
+
+func hashScreen(fileName string) (screenHash, error) {
+	img, err := decodeImage(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := grayGrid(img, img.Bounds())
+	hash := make(screenHash, len(grid))
+	for i, luminance := range grid {
+		hash[i] = uint8(luminance / 0x101)
+	}
+	return hash, nil
+}
+
+// distance returns the number of cells differing by more than the margin.
+func (h screenHash) distance(other screenHash) int {
+	if len(h) != len(other) {
+		return len(h)
+	}
+
+	distance := 0
+	for i := range h {
+		diff := int(h[i]) - int(other[i])
+		if diff > screenHashMargin || diff < -screenHashMargin {
+			distance++
+		}
+	}
+	return distance
+}
+
+// screenPoller paces the screenshots of the screen based boot, and tells
+// whether the OCR can be skipped because the screen hasn't changed since the
+// last recognized screenshot. The polling interval backs off while the screen
+// is unchanged, and is the shortest right after a boot command is typed.
+type screenPoller struct {
+	interval time.Duration
+	// The hash of the last recognized screenshot, and when it was recognized
+	hash       screenHash
+	recognized time.Time
+
+	ocrCalls     int
+	skippedCalls int
+}
+
+func newScreenPoller() *screenPoller {
+	return &screenPoller{interval: defaultPollInterval}
+}
+
+// unchanged returns whether the screenshot looks like the last recognized
+// one, recognized recently enough for the OCR to be skipped. Otherwise, the
+// screenshot is to be recognized and becomes the reference.
+func (p *screenPoller) unchanged(fileName string, now time.Time) bool {
+	hash, err := hashScreen(fileName)
+	if err == nil && p.hash != nil && now.Sub(p.recognized) < maxOCRInterval &&
+		hash.distance(p.hash) <= screenHashTolerance {
+		p.skippedCalls++
+		p.interval = min(p.interval*2, maxPollInterval)
+		return true
+	}
+
+	// A screenshot which can't be hashed is always recognized
+	p.hash = hash
+	p.recognized = now
+	p.ocrCalls++
+	if p.interval > defaultPollInterval {
+		p.interval = defaultPollInterval
+	}
+	return false
+}
+
+// typed speeds up the polling, as the screen is about to change.
+func (p *screenPoller) typed() {
+	p.interval = minPollInterval
+}
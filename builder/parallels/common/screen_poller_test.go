@@ -0,0 +1,122 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestScreen writes a white screenshot of 640x480 pixels, with black
+// rectangles.
+func writeTestScreen(t *testing.T, fileName string, rects ...image.Rectangle) {
+	img := image.NewGray(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	for _, rect := range rects {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestScreenHash(t *testing.T) {
+	dir := t.TempDir()
+	text := image.Rect(100, 100, 300, 130)
+	screens := map[string][]image.Rectangle{
+		"screen": {text},
+		// A blinking cursor
+		"cursor": {text, image.Rect(310, 100, 312, 130)},
+		// Another text
+		"other": {text, image.Rect(100, 200, 400, 230)},
+	}
+
+	hashes := make(map[string]screenHash)
+	for name, rects := range screens {
+		fileName := filepath.Join(dir, name+".png")
+		writeTestScreen(t, fileName, rects...)
+		hash, err := hashScreen(fileName)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		hashes[name] = hash
+	}
+
+	if d := hashes["screen"].distance(hashes["cursor"]); d > screenHashTolerance {
+		t.Fatalf("the cursor should be tolerated: %d", d)
+	}
+	if d := hashes["screen"].distance(hashes["other"]); d <= screenHashTolerance {
+		t.Fatalf("the other text should not be tolerated: %d", d)
+	}
+
+	if _, err := hashScreen(filepath.Join(dir, "missing.png")); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestScreenPoller(t *testing.T) {
+	dir := t.TempDir()
+	screen := filepath.Join(dir, "screen.png")
+	other := filepath.Join(dir, "other.png")
+	writeTestScreen(t, screen, image.Rect(100, 100, 300, 130))
+	writeTestScreen(t, other, image.Rect(100, 200, 400, 230))
+
+	poller := newScreenPoller()
+	now := time.Now()
+	if poller.unchanged(screen, now) {
+		t.Fatal("the first screenshot should be recognized")
+	}
+
+	// The polling backs off while the screen is unchanged
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, maxPollInterval, maxPollInterval}
+	for i, interval := range expected {
+		now = now.Add(poller.interval)
+		if !poller.unchanged(screen, now) {
+			t.Fatalf("%d: the screen should be unchanged", i)
+		}
+		if poller.interval != interval {
+			t.Fatalf("%d: bad interval: %s", i, poller.interval)
+		}
+	}
+
+	if poller.unchanged(other, now) {
+		t.Fatal("the screen should have changed")
+	}
+	if poller.interval != defaultPollInterval {
+		t.Fatalf("bad interval: %s", poller.interval)
+	}
+
+	poller.typed()
+	if poller.interval != minPollInterval {
+		t.Fatalf("bad interval: %s", poller.interval)
+	}
+
+	// The OCR runs at least every maxOCRInterval
+	if poller.unchanged(other, now.Add(maxOCRInterval)) {
+		t.Fatal("the screenshot should be recognized")
+	}
+
+	if poller.ocrCalls != 3 || poller.skippedCalls != 4 {
+		t.Fatalf("bad counts: %d, %d", poller.ocrCalls, poller.skippedCalls)
+	}
+}
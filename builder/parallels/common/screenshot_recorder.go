@@ -0,0 +1,164 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// screenshotGIFName is the name of the animated GIF of the screenshots.
+const screenshotGIFName = "boot.gif"
+
+// screenshotGIFDelay is the delay between the frames of the animated GIF, in
+// 100ths of a second.
+const screenshotGIFDelay = 100
+
+// screenshotRecord is the JSON sidecar of a recorded screenshot.
+type screenshotRecord struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Text        string    `json:"text"`
+	ScreenName  string    `json:"screen_name"`
+	BootCommand string    `json:"boot_command"`
+}
+
+// screenshotRecorder keeps the distinct screenshots of the screen based boot
+// in a directory, along with their JSON sidecars. The screenshots are
+// deduplicated by the hash of their pixels.
+type screenshotRecorder struct {
+	dir    string
+	hashes map[string]bool
+	frames []string
+
+	// The sidecar of the last recorded screenshot, nil if it was a duplicate
+	last     *screenshotRecord
+	lastPath string
+}
+
+func newScreenshotRecorder(dir string) (*screenshotRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &screenshotRecorder{
+		dir:    dir,
+		hashes: make(map[string]bool),
+	}, nil
+}
+
+// record keeps the screenshot, unless an identical one was already kept,
+// along with the texts recognized on it.
+func (r *screenshotRecorder) record(fileName string, texts ScreenTexts, screenName string) error {
+	r.last = nil
+
+	img, err := decodeImage(fileName)
+	if err != nil {
+		return err
+	}
+	hash := imageHash(img)
+	if r.hashes[hash] {
+		return nil
+	}
+	r.hashes[hash] = true
+
+	base := filepath.Join(r.dir, fmt.Sprintf("screenshot-%04d", len(r.frames)+1))
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".png", data, 0644); err != nil {
+		return err
+	}
+	r.frames = append(r.frames, base+".png")
+
+	var lines []string
+	for _, text := range texts {
+		lines = append(lines, text.Text)
+	}
+
+	r.last = &screenshotRecord{
+		Timestamp:  time.Now(),
+		Text:       strings.Join(lines, "\n"),
+		ScreenName: screenName,
+	}
+	r.lastPath = base + ".json"
+	return r.writeLast()
+}
+
+// recordBootCommand adds the boot command sent on the screen to the sidecar of
+// the last screenshot.
+func (r *screenshotRecorder) recordBootCommand(bootCommand string) error {
+	if r.last == nil {
+		return nil
+	}
+	r.last.BootCommand = bootCommand
+	return r.writeLast()
+}
+
+func (r *screenshotRecorder) writeLast() error {
+	data, err := json.MarshalIndent(r.last, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.lastPath, data, 0644)
+}
+
+// writeGIF assembles the screenshots into an animated GIF, and returns its
+// path.
+func (r *screenshotRecorder) writeGIF() (string, error) {
+	anim := &gif.GIF{}
+	for _, frame := range r.frames {
+		img, err := decodeImage(frame)
+		if err != nil {
+			return "", err
+		}
+		paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, img.Bounds().Min)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, screenshotGIFDelay)
+	}
+	if len(anim.Image) == 0 {
+		return "", fmt.Errorf("no screenshot recorded")
+	}
+
+	path := filepath.Join(r.dir, screenshotGIFName)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err := gif.EncodeAll(file, anim); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// imageHash returns the hash of the pixels of the image, regardless of how
+// it is encoded.
+func imageHash(img image.Image) string {
+	h := sha256.New()
+	bounds := img.Bounds()
+	fmt.Fprintf(h, "%d,%d,%d,%d;", bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+	pixel := make([]byte, 8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			pixel[0], pixel[1] = byte(r>>8), byte(r)
+			pixel[2], pixel[3] = byte(g>>8), byte(g)
+			pixel[4], pixel[5] = byte(b>>8), byte(b)
+			pixel[6], pixel[7] = byte(a>>8), byte(a)
+			h.Write(pixel)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
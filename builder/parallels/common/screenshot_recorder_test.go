@@ -0,0 +1,101 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, fileName string, c color.Color) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestScreenshotRecorder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "screenshots")
+	recorder, err := newScreenshotRecorder(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	screenshot := filepath.Join(t.TempDir(), "screenshot.png")
+	texts := ScreenTexts{{Text: "Select your language"}}
+
+	writeTestPNG(t, screenshot, color.White)
+	if err := recorder.record(screenshot, texts, "language"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := recorder.recordBootCommand("<enter>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The same image is recorded once
+	if err := recorder.record(screenshot, texts, "language"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := recorder.recordBootCommand("<tab>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	writeTestPNG(t, screenshot, color.Black)
+	if err := recorder.record(screenshot, texts, "empty"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(recorder.frames) != 2 {
+		t.Fatalf("bad: %#v", recorder.frames)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "screenshot-0001.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var record screenshotRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if record.ScreenName != "language" || record.BootCommand != "<enter>" || record.Text != "Select your language" {
+		t.Fatalf("bad: %#v", record)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "screenshot-0002.png")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path, err := recorder.writeGIF()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer file.Close()
+	anim, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(anim.Image) != 2 {
+		t.Fatalf("bad frame count: %d", len(anim.Image))
+	}
+}
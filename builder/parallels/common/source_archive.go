@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// extractSourceArchive extracts the archive into the destination directory.
+// The compression is detected from the content of the archive rather than
+// from its extension, so that packed VMs (.pvmp) are handled the same way as
+// plain, gzip and zstd compressed tarballs.
+func extractSourceArchive(archivePath, dstDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		r = gzr
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	return extractTar(r, dstDir)
+}
+
+func extractTar(r io.Reader, dstDir string) error {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+	// The directory the archive is extracted into, once resolved, e.g. on
+	// macOS, where the temporary directories are behind a symlink
+	realDstDir, err := filepath.EvalSymlinks(dstDir)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %s", err)
+		}
+
+		// Never write outside of the destination directory, neither by the
+		// name of the entry, nor through a symlink extracted before
+		target := filepath.Join(dstDir, header.Name)
+		if !isInDir(dstDir, target) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		if err := checkResolvedInDir(realDstDir, target); err != nil {
+			return fmt.Errorf("invalid path in archive: %s: %s", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// Replace, rather than write through, a symlink of the same name
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(header.Linkname) {
+				return fmt.Errorf("invalid symlink in archive: %s -> %s", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+			if err != nil {
+				return err
+			}
+			if !isInDir(realDstDir, filepath.Join(parent, header.Linkname)) {
+				return fmt.Errorf("invalid symlink in archive: %s -> %s", header.Name, header.Linkname)
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			// Other entries (devices, fifos...) have nothing to do in a VM bundle
+			continue
+		}
+	}
+
+	// The symlinks are resolved once all of them are extracted, as they
+	// may point to each other
+	return filepath.Walk(dstDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		if err := checkResolvedInDir(realDstDir, path); err != nil {
+			return fmt.Errorf("invalid symlink in archive: %s: %s", path, err)
+		}
+		return nil
+	})
+}
+
+// isInDir returns whether the path is the directory or is in it, comparing
+// the paths only.
+func isInDir(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// checkResolvedInDir returns an error if the path, once the symlinks of its
+// existing part are resolved, is out of the resolved directory.
+func checkResolvedInDir(realDir, path string) error {
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if os.IsNotExist(err) && existing == path {
+		// A dangling symlink, checked when created, not written through
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isInDir(realDir, resolved) {
+		return fmt.Errorf("resolves to %s, outside of the destination", resolved)
+	}
+	return nil
+}
+
+// findVMBundle returns the path to the first PVM or MACVM directory found
+// in the given directory tree.
+func findVMBundle(dir string) (string, error) {
+	bundle := ""
+	errFound := errors.New("found")
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (strings.HasSuffix(path, ".pvm") || strings.HasSuffix(path, ".macvm")) {
+			bundle = path
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && err != errFound {
+		return "", err
+	}
+
+	if bundle == "" {
+		return "", fmt.Errorf("no PVM or MACVM directory found in the archive")
+	}
+	return bundle, nil
+}
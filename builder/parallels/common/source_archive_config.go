@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// The archive formats which can be used as the source of a build.
+var sourceArchiveExtensions = []string{"pvmp", "tar", "tar.gz", "tgz", "tar.zst", "tzst"}
+
+// SourceArchiveConfig contains the configuration for a source VM which is
+// distributed as an archive. It acts the same as ISOConfig: the archive is
+// downloaded (or copied) into the Packer cache, verified against its
+// checksum, and then extracted. The extracted VM is cached as well, so that
+// the following builds using the same archive don't have to extract it again.
+type SourceArchiveConfig struct {
+	// A URL to an archive containing the source VM. Supported formats are
+	// `.pvmp`, `.tar`, `.tar.gz` (`.tgz`) and `.tar.zst` (`.tzst`). The
+	// archive must contain a single PVM (or MACVM) directory. Only one of
+	// source_path, source_url or source_vm can be specified.
+	SourceURL string `mapstructure:"source_url" required:"false"`
+	// The checksum of the archive specified by source_url. The format is the
+	// same as for iso_checksum, e.g. "sha256:{$checksum}" or "file:{$path}".
+	// It is required when source_url is set. The extracted VM is cached by
+	// this checksum, which is not possible when it is set to "none".
+	SourceChecksum string `mapstructure:"source_checksum" required:"false"`
+}
+
+// Prepare validates the source archive URL and resolves its checksum.
+func (c *SourceArchiveConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs []error) {
+	if c.SourceURL == "" {
+		if c.SourceChecksum != "" {
+			errs = append(errs, fmt.Errorf("source_checksum can only be used with source_url"))
+		}
+		return nil, errs
+	}
+
+	extension := SourceArchiveExtension(c.SourceURL)
+	if extension == "" {
+		errs = append(errs, fmt.Errorf("source_url has an unsupported archive format. Must be one of: %v",
+			sourceArchiveExtensions))
+	}
+
+	config := new(commonsteps.ISOConfig)
+	config.ISOChecksum = c.SourceChecksum
+	config.RawSingleISOUrl = c.SourceURL
+	config.TargetExtension = extension
+
+	isoWarnings, isoErrs := config.Prepare(ctx)
+	for _, err := range isoErrs {
+		errs = append(errs, fmt.Errorf("source_url: %s", err))
+	}
+
+	c.SourceChecksum = config.ISOChecksum
+	if len(config.ISOUrls) > 0 {
+		c.SourceURL = config.ISOUrls[0]
+	}
+
+	return isoWarnings, errs
+}
+
+// DownloadURL returns the URL to download the archive from. The archive is
+// extracted by the builder itself, so go-getter must not decompress it.
+func (c *SourceArchiveConfig) DownloadURL() string {
+	u, err := url.Parse(c.SourceURL)
+	if err != nil {
+		return c.SourceURL
+	}
+	q := u.Query()
+	if q.Get("archive") == "" {
+		q.Set("archive", "false")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// SourceArchiveExtension returns the archive extension of the given URL, or
+// an empty string if the format is not supported.
+func SourceArchiveExtension(rawURL string) string {
+	// Strip the query string, go-getter options are passed through it
+	path := strings.ToLower(strings.SplitN(rawURL, "?", 2)[0])
+
+	extension := ""
+	for _, ext := range sourceArchiveExtensions {
+		// Longest match wins, so that "tar.gz" is preferred over "tar"
+		if strings.HasSuffix(path, "."+ext) && len(ext) > len(extension) {
+			extension = ext
+		}
+	}
+	return extension
+}
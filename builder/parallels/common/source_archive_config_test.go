@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+const testSourceChecksum = "sha256:6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+
+func TestSourceArchiveConfigPrepare(t *testing.T) {
+	// Test with empty
+	c := new(SourceArchiveConfig)
+	_, errs := c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	// Test with a good one
+	c = new(SourceArchiveConfig)
+	c.SourceURL = "https://example.com/vms/ubuntu.pvm.tar.gz"
+	c.SourceChecksum = testSourceChecksum
+	_, errs = c.Prepare(interpolate.NewContext())
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	// Test with a checksum but no URL
+	c = new(SourceArchiveConfig)
+	c.SourceChecksum = testSourceChecksum
+	_, errs = c.Prepare(interpolate.NewContext())
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	// Test with an unsupported format
+	c = new(SourceArchiveConfig)
+	c.SourceURL = "https://example.com/vms/ubuntu.zip"
+	c.SourceChecksum = testSourceChecksum
+	_, errs = c.Prepare(interpolate.NewContext())
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+
+	// Test without a checksum
+	c = new(SourceArchiveConfig)
+	c.SourceURL = "https://example.com/vms/ubuntu.pvmp"
+	_, errs = c.Prepare(interpolate.NewContext())
+	if len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestSourceArchiveConfig_DownloadURL(t *testing.T) {
+	c := &SourceArchiveConfig{SourceURL: "https://example.com/ubuntu.tar.gz"}
+	if u := c.DownloadURL(); !strings.HasSuffix(u, "?archive=false") {
+		t.Fatalf("bad: %s", u)
+	}
+
+	c = &SourceArchiveConfig{SourceURL: "https://example.com/ubuntu.tar.gz?archive=tar.gz"}
+	if u := c.DownloadURL(); !strings.HasSuffix(u, "?archive=tar.gz") {
+		t.Fatalf("bad: %s", u)
+	}
+}
+
+func TestSourceArchiveExtension(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/ubuntu.pvmp":             "pvmp",
+		"https://example.com/ubuntu.tar":              "tar",
+		"https://example.com/ubuntu.pvm.tar.gz":       "tar.gz",
+		"https://example.com/ubuntu.TGZ":              "tgz",
+		"https://example.com/ubuntu.tar.zst?token=x":  "tar.zst",
+		"/Users/packer/ubuntu.tzst":                   "tzst",
+		"https://example.com/ubuntu.zip":              "",
+		"https://example.com/download?file=ubuntu.gz": "",
+	}
+
+	for url, expected := range cases {
+		if ext := SourceArchiveExtension(url); ext != expected {
+			t.Errorf("%s: expected %q, got %q", url, expected, ext)
+		}
+	}
+}
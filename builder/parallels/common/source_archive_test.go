@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func testTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func testCompress(t *testing.T, format string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		w = zw
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractSourceArchive(t *testing.T) {
+	tarball := testTarball(t, map[string]string{
+		"ubuntu.pvm/config.pvs":                "<ParallelsVirtualMachine/>",
+		"ubuntu.pvm/harddisk.hdd/harddisk.hds": "data",
+	})
+
+	for _, format := range []string{"tar", "gzip", "zstd"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, "source.archive")
+			if err := os.WriteFile(archivePath, testCompress(t, format, tarball), 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			dstDir := filepath.Join(dir, "extracted")
+			if err := extractSourceArchive(archivePath, dstDir); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(dstDir, "ubuntu.pvm", "harddisk.hdd", "harddisk.hds"))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if string(content) != "data" {
+				t.Fatalf("bad: %s", content)
+			}
+
+			bundle, err := findVMBundle(dstDir)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if bundle != filepath.Join(dstDir, "ubuntu.pvm") {
+				t.Fatalf("bad: %s", bundle)
+			}
+		})
+	}
+}
+
+func TestExtractSourceArchive_pathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "source.tar")
+	tarball := testTarball(t, map[string]string{"../evil": "data"})
+	if err := os.WriteFile(archivePath, tarball, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dstDir := filepath.Join(dir, "extracted")
+	if err := extractSourceArchive(archivePath, dstDir); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); err == nil {
+		t.Fatal("file should not be written outside of the destination")
+	}
+}
+
+// testTarballEntries returns a tarball of the entries, in order. The entries
+// with a link name are symlinks.
+func testTarballEntries(t *testing.T, entries []tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range entries {
+		header.Mode = 0644
+		header.Typeflag = tar.TypeReg
+		if header.Linkname != "" {
+			header.Typeflag = tar.TypeSymlink
+		}
+		if err := tw.WriteHeader(&header); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(make([]byte, header.Size)); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractSourceArchive_symlink(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "source.tar")
+	tarball := testTarballEntries(t, []tar.Header{
+		{Name: "ubuntu.pvm/harddisk.hdd/harddisk.hds", Size: 4},
+		{Name: "ubuntu.pvm/harddisk.hds", Linkname: "harddisk.hdd/harddisk.hds"},
+	})
+	if err := os.WriteFile(archivePath, tarball, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dstDir := filepath.Join(dir, "extracted")
+	if err := extractSourceArchive(archivePath, dstDir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dstDir, "ubuntu.pvm", "harddisk.hds"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(content) != 4 {
+		t.Fatalf("bad: %q", content)
+	}
+}
+
+func TestExtractSourceArchive_maliciousSymlink(t *testing.T) {
+	cases := map[string][]tar.Header{
+		"absolute": {
+			{Name: "link", Linkname: "/"},
+			{Name: "link/evil", Size: 4},
+		},
+		"relative": {
+			{Name: "link", Linkname: "../.."},
+			{Name: "link/evil", Size: 4},
+		},
+		"nested": {
+			{Name: "ubuntu.pvm/link", Linkname: "../../evil"},
+		},
+		// The link is in the destination when created, but not once the
+		// link it goes through is extracted
+		"chained": {
+			{Name: "link", Linkname: "dot/.."},
+			{Name: "dot", Linkname: "."},
+		},
+	}
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, "source.tar")
+			if err := os.WriteFile(archivePath, testTarballEntries(t, entries), 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if err := extractSourceArchive(archivePath, filepath.Join(dir, "a", "extracted")); err == nil {
+				t.Fatal("should error")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "a", "evil")); err == nil {
+				t.Fatal("file should not be written outside of the destination")
+			}
+		})
+	}
+}
+
+func TestExtractSourceArchive_writeThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	dstDir := filepath.Join(dir, "extracted")
+	for _, d := range []string{outside, dstDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// A symlink out of the destination, left over by a previous extraction
+	if err := os.Symlink(outside, filepath.Join(dstDir, "link")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	archivePath := filepath.Join(dir, "source.tar")
+	tarball := testTarballEntries(t, []tar.Header{
+		{Name: "link/evil", Size: 4},
+	})
+	if err := os.WriteFile(archivePath, tarball, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := extractSourceArchive(archivePath, dstDir); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil")); err == nil {
+		t.Fatal("file should not be written through the symlink")
+	}
+}
+
+func TestFindVMBundle_missing(t *testing.T) {
+	if _, err := findVMBundle(t.TempDir()); err == nil {
+		t.Fatal("should error")
+	}
+}
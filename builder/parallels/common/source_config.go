@@ -0,0 +1,79 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// SourceConfig contains the configuration for the source VM the builders
+// importing a VM start from: a PVM (or MACVM) directory, an archive of it
+// or a registered VM.
+type SourceConfig struct {
+	SourceArchiveConfig `mapstructure:",squash"`
+	// The path to a PVM (or MACVM) directory that acts as the source
+	// of this build. Required unless source_url or source_vm is specified.
+	SourcePath string `mapstructure:"source_path" required:"true"`
+	// The name or UUID of a VM or template, already registered in Parallels
+	// Desktop, that acts as the source of this build. The VM must be stopped.
+	// It is cloned without being modified, except for the snapshot taken when
+	// clone_mode is "linked", and is never unregistered. Only one of
+	// source_path, source_url or source_vm can be specified.
+	SourceVM string `mapstructure:"source_vm" required:"false"`
+	// The name or ID of the snapshot of source_vm to clone from. Only valid
+	// with source_vm and when clone_mode is "linked". By default a new
+	// snapshot of source_vm is taken.
+	SourceSnapshot string `mapstructure:"source_snapshot" required:"false"`
+}
+
+// Prepare validates the source of the VM named vmName, cloned as set by the
+// clone config, which must be prepared first.
+func (c *SourceConfig) Prepare(ctx *interpolate.Context, vmName string, clone *CloneConfig) (warnings []string, errs []error) {
+	warnings, errs = c.SourceArchiveConfig.Prepare(ctx)
+
+	sources := 0
+	for _, source := range []string{c.SourcePath, c.SourceURL, c.SourceVM} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		errs = append(errs, fmt.Errorf("only one of source_path, source_url or source_vm can be specified"))
+	} else if sources == 0 {
+		errs = append(errs, fmt.Errorf("one of source_path, source_url or source_vm is required"))
+	} else if c.SourcePath != "" {
+		if _, err := os.Stat(c.SourcePath); err != nil {
+			errs = append(errs, fmt.Errorf("source_path is invalid: %s", err))
+		}
+	}
+
+	// The source extracted from source_url is removed, or shared by the
+	// other builds once cached, so it can't be the parent of the VM
+	if c.SourceURL != "" && clone.IsLinked() && !clone.FlattenLinkedClone {
+		errs = append(errs,
+			fmt.Errorf("clone_mode \"linked\" can only be used with source_url when flatten_linked_clone is set"))
+	}
+
+	if c.SourceVM != "" && c.SourceVM == vmName {
+		errs = append(errs, fmt.Errorf("source_vm and vm_name must be different"))
+	}
+
+	if c.SourceSnapshot != "" {
+		if c.SourceVM == "" {
+			errs = append(errs, fmt.Errorf("source_snapshot can only be used with source_vm"))
+		}
+		if !clone.IsLinked() {
+			errs = append(errs,
+				fmt.Errorf("source_snapshot can only be used when clone_mode is \"linked\", "+
+					"set flatten_linked_clone to get a standalone VM"))
+		}
+	}
+
+	return warnings, errs
+}
@@ -0,0 +1,60 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestSourceConfigPrepare(t *testing.T) {
+	sourcePath := t.TempDir()
+	linked := &CloneConfig{CloneMode: CloneModeLinked}
+	flattened := &CloneConfig{CloneMode: CloneModeLinked, FlattenLinkedClone: true}
+	full := &CloneConfig{CloneMode: CloneModeFull}
+
+	cases := []struct {
+		name   string
+		config SourceConfig
+		clone  *CloneConfig
+		err    string
+	}{
+		{"source_path", SourceConfig{SourcePath: sourcePath}, full, ""},
+		{"source_vm", SourceConfig{SourceVM: "base"}, full, ""},
+		{"no source", SourceConfig{}, full, "one of source_path, source_url or source_vm is required"},
+		{"several sources", SourceConfig{SourcePath: sourcePath, SourceVM: "base"}, full,
+			"only one of source_path, source_url or source_vm can be specified"},
+		{"invalid source_path", SourceConfig{SourcePath: sourcePath + "/missing"}, full, "source_path is invalid"},
+		{"source_vm as vm_name", SourceConfig{SourceVM: "foo"}, full, "source_vm and vm_name must be different"},
+		{"linked source_url", SourceConfig{SourceArchiveConfig: SourceArchiveConfig{
+			SourceURL: "http://example.com/base.pvmp", SourceChecksum: "none"}}, linked,
+			"can only be used with source_url when flatten_linked_clone is set"},
+		{"flattened source_url", SourceConfig{SourceArchiveConfig: SourceArchiveConfig{
+			SourceURL: "http://example.com/base.pvmp", SourceChecksum: "none"}}, flattened, ""},
+		{"source_snapshot", SourceConfig{SourceVM: "base", SourceSnapshot: "clean"}, linked, ""},
+		{"source_snapshot without source_vm", SourceConfig{SourcePath: sourcePath, SourceSnapshot: "clean"}, linked,
+			"source_snapshot can only be used with source_vm"},
+		{"source_snapshot of a full clone", SourceConfig{SourceVM: "base", SourceSnapshot: "clean"}, full,
+			"source_snapshot can only be used when clone_mode is \"linked\""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, errs := c.config.Prepare(interpolate.NewContext(), "foo", c.clone)
+			if c.err == "" {
+				if len(errs) > 0 {
+					t.Fatalf("err: %#v", errs)
+				}
+				return
+			}
+			for _, err := range errs {
+				if strings.Contains(err.Error(), c.err) {
+					return
+				}
+			}
+			t.Fatalf("should have error %q: %v", c.err, errs)
+		})
+	}
+}
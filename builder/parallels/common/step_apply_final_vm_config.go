@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// CaptureVMSettings saves the current settings of the VM, so that they can
+// be restored at the end of the build. It must be called before the build
+// settings are applied.
+func CaptureVMSettings(state multistep.StateBag, driver Driver, vmName string) {
+	settings, err := driver.VMSettings(vmName)
+	if err != nil {
+		log.Printf("Could not capture the original VM settings: %s", err)
+		return
+	}
+	state.Put("original_vm_settings", settings)
+}
+
+// StepApplyFinalVMConfig is a step that applies the final settings to the VM
+// once it is shut down.
+//
+// Uses:
+//
+//	driver Driver
+//	vmName string
+//	ui     packersdk.Ui
+//	original_vm_settings map[string]string - only if RestoreOriginal is set
+//
+// Produces:
+//
+//	<nothing>
+type StepApplyFinalVMConfig struct {
+	Config FinalVMConfig
+}
+
+// Run applies the final VM settings.
+func (s *StepApplyFinalVMConfig) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config.IsEmpty() {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Applying the final VM configuration...")
+
+	settings := s.Config.Settings()
+
+	if s.Config.RestoreOriginal {
+		original, ok := state.GetOk("original_vm_settings")
+		if !ok {
+			ui.Error("The original VM settings were not captured, they can't be restored")
+		} else {
+			for _, option := range sortedKeys(original.(map[string]string)) {
+				if _, ok := settings[option]; ok {
+					// Explicit settings take precedence
+					continue
+				}
+
+				value := original.(map[string]string)[option]
+				// Restoring is best effort, the values reported by older
+				// versions of Parallels Desktop may not be accepted back.
+				if err := driver.Prlctl("set", vmName, option, value); err != nil {
+					ui.Error(fmt.Sprintf("Error restoring %s to %q: %s", option, value, err))
+				}
+			}
+		}
+	}
+
+	for _, option := range sortedKeys(settings) {
+		if err := driver.Prlctl("set", vmName, option, settings[option]); err != nil {
+			err := fmt.Errorf("Error applying the final VM configuration: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing.
+func (s *StepApplyFinalVMConfig) Cleanup(state multistep.StateBag) {}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
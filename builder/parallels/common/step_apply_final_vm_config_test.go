@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepApplyFinalVMConfig_impl(t *testing.T) {
+	var _ multistep.Step = new(StepApplyFinalVMConfig)
+}
+
+func TestStepApplyFinalVMConfig(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFinalVMConfig)
+	step.Config.RestoreOriginal = true
+	step.Config.StartupView = "window"
+
+	state.Put("vmName", "foo")
+	state.Put("original_vm_settings", map[string]string{
+		"--startup-view":   "same",
+		"--shared-profile": "on",
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// The explicit settings take precedence over the original ones
+	expected := [][]string{
+		{"set", "foo", "--shared-profile", "on"},
+		{"set", "foo", "--startup-view", "window"},
+	}
+	if !reflect.DeepEqual(driver.PrlctlCalls, expected) {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
+
+// Every setting changed by the default settings is restored.
+func TestStepApplyFinalVMConfig_restoreDefaultSettings(t *testing.T) {
+	out := `[
+  {
+    "ID": "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+    "Startup and Shutdown": {
+      "Startup view": "window",
+      "On shutdown": "keep window",
+      "On window close": "close window"
+    },
+    "USB and Bluetooth": {"Automatic sharing cameras": "on"},
+    "SmartGuard": {"enabled": true},
+    "Miscellaneous Sharing": {"Shared cloud": "on"},
+    "Shared Profile": {"enabled": true},
+    "SmartMount": {"enabled": true},
+    "Shared Applications": {
+      "Guest-to-host apps sharing": "on",
+      "Host-to-guest apps sharing": "on",
+      "Show guest notifications": "on"
+    }
+  }
+]`
+	original, err := parseVMSettings(out)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	step := new(StepApplyFinalVMConfig)
+	step.Config.RestoreOriginal = true
+	state.Put("vmName", "foo")
+	state.Put("original_vm_settings", original)
+
+	driver := state.Get("driver").(*DriverMock)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	restored := make(map[string]string)
+	for _, call := range driver.PrlctlCalls {
+		restored[call[2]] = call[3]
+	}
+	for capability, settings := range defaultSettings {
+		for _, setting := range settings {
+			value, ok := restored[setting.Option]
+			if !ok {
+				t.Errorf("%s: %s should be restored", capability, setting.Option)
+			} else if value == setting.Value {
+				t.Errorf("%s: %s should be restored to its original value: %q", capability, setting.Option, value)
+			}
+		}
+	}
+}
+
+func TestStepApplyFinalVMConfig_empty(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFinalVMConfig)
+
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.PrlctlCalls) != 0 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestStepApplyFinalVMConfig_restoreCoherence(t *testing.T) {
+	state := testState(t)
+	step := new(StepApplyFinalVMConfig)
+	step.Config.RestoreOriginal = true
+
+	// The VM started in Coherence before the build
+	state.Put("vmName", "foo")
+	state.Put("original_vm_settings", map[string]string{"--startup-view": "coherence"})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	expected := [][]string{{"set", "foo", "--startup-view", "coherence"}}
+	if !reflect.DeepEqual(driver.PrlctlCalls, expected) {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
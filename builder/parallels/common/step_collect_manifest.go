@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepCollectManifest is a step that collects the information about the
+// built VM for the build manifest. It must run last, while the VM is still
+// registered. The file checksums are added when the artifact is created.
+//
+// Uses:
+//
+//	driver Driver
+//	vmName string
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	manifest *Manifest
+type StepCollectManifest struct {
+	// The source ISO, IPSW or VM of the build and its checksum
+	Source         string
+	SourceChecksum string
+	// The time the build started
+	StartTime time.Time
+}
+
+// Run collects the information about the VM.
+func (s *StepCollectManifest) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Collecting the build manifest...")
+	vm, err := driver.LookupVM(vmName)
+	if err != nil {
+		err = fmt.Errorf("Error collecting the build manifest: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	version, err := driver.Version()
+	if err != nil {
+		err = fmt.Errorf("Error collecting the build manifest: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Printf("Could not determine the host name: %s", err)
+	}
+
+	manifest := &Manifest{
+		VMName:           vm.Name,
+		UUID:             vm.ID,
+		MACAddresses:     vm.MACAddresses,
+		GuestOS:          vm.GuestOS,
+		CPUs:             vm.CPUs,
+		MemoryMB:         vm.Memory,
+		Disks:            make([]ManifestDisk, 0, len(vm.Disks)),
+		ParallelsVersion: version,
+		Host:             host,
+		HostArch:         runtime.GOARCH,
+		Source:           s.Source,
+		SourceChecksum:   s.SourceChecksum,
+	}
+
+	for _, disk := range vm.Disks {
+		allocated, err := allocatedSize(disk.Image)
+		if err != nil {
+			log.Printf("Could not determine the allocated size of %s: %s", disk.Image, err)
+		}
+		manifest.Disks = append(manifest.Disks, ManifestDisk{
+			Name:               disk.Name,
+			Path:               disk.Image,
+			Format:             disk.Type,
+			VirtualSizeBytes:   disk.Size * 1024 * 1024,
+			AllocatedSizeBytes: allocated,
+		})
+	}
+
+	if !s.StartTime.IsZero() {
+		manifest.BuildDuration = time.Since(s.StartTime).Round(time.Second).String()
+	}
+
+	state.Put("manifest", manifest)
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing.
+func (s *StepCollectManifest) Cleanup(state multistep.StateBag) {}
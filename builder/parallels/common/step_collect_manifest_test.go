@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCollectManifest_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCollectManifest)
+}
+
+func TestStepCollectManifest(t *testing.T) {
+	state := testState(t)
+	step := &StepCollectManifest{
+		Source:         "https://example.com/ubuntu.iso",
+		SourceChecksum: "sha256:abc",
+		StartTime:      time.Now().Add(-time.Minute),
+	}
+
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "19.1.0"
+	driver.LookupVMResult = &RegisteredVM{
+		ID:           "{8f8c6a5e-1b2c-4d3e-9f00-123456789abc}",
+		Name:         "foo",
+		GuestOS:      "ubuntu",
+		CPUs:         2,
+		Memory:       2048,
+		MACAddresses: []string{"001C42AABBCC"},
+		Disks:        []RegisteredVMDisk{{Name: "hdd0", Image: "/i/dont/exist.hdd", Size: 1024}},
+	}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	manifest := state.Get("manifest").(*Manifest)
+	if manifest.UUID != driver.LookupVMResult.ID || manifest.ParallelsVersion != "19.1.0" {
+		t.Fatalf("bad: %#v", manifest)
+	}
+	if manifest.Source != step.Source || manifest.SourceChecksum != step.SourceChecksum {
+		t.Fatalf("bad source: %#v", manifest)
+	}
+	if len(manifest.Disks) != 1 || manifest.Disks[0].VirtualSizeBytes != 1024*1024*1024 {
+		t.Fatalf("bad disks: %#v", manifest.Disks)
+	}
+	if manifest.BuildDuration != "1m0s" {
+		t.Fatalf("bad duration: %s", manifest.BuildDuration)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepConvertToTemplate is a step that converts the VM to a Parallels
+// Desktop template at the end of the build.
+//
+// Uses:
+//
+//	driver Driver
+//	vmName string
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type StepConvertToTemplate struct {
+	Skip bool
+}
+
+// Run converts the VM to a template.
+func (s *StepConvertToTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Skip {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Converting the VM to a template...")
+	if err := driver.Prlctl("set", vmName, "--template", "on"); err != nil {
+		err = fmt.Errorf("Error converting the VM to a template: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing.
+func (s *StepConvertToTemplate) Cleanup(state multistep.StateBag) {}
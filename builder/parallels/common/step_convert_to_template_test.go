@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConvertToTemplate_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConvertToTemplate)
+}
+
+func TestStepConvertToTemplate(t *testing.T) {
+	state := testState(t)
+	step := new(StepConvertToTemplate)
+
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test the driver
+	expected := []string{"set", "foo", "--template", "on"}
+	if len(driver.PrlctlCalls) != 1 || len(driver.PrlctlCalls[0]) != len(expected) {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+	for i, arg := range expected {
+		if driver.PrlctlCalls[0][i] != arg {
+			t.Fatalf("bad call: %#v", driver.PrlctlCalls[0])
+		}
+	}
+}
+
+func TestStepConvertToTemplate_skip(t *testing.T) {
+	state := testState(t)
+	step := &StepConvertToTemplate{Skip: true}
+
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.PrlctlCalls) != 0 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// The marker file written once an archive is completely extracted into the cache
+const extractedMarker = ".packer-extracted"
+
+// StepExtractSource extracts the downloaded source VM archive. If the archive
+// has a checksum, the extracted VM is kept in the Packer cache and reused by
+// the following builds.
+//
+// Uses:
+//
+//	source_archive_path string
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	source_path string - The path to the extracted VM
+type StepExtractSource struct {
+	Checksum string
+
+	tempDir string
+}
+
+// Run extracts the source VM archive.
+func (s *StepExtractSource) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	archivePath := state.Get("source_archive_path").(string)
+
+	var dstDir string
+	if s.Checksum != "" && s.Checksum != "none" {
+		sum := sha1.Sum([]byte(s.Checksum))
+		cacheDir, err := packersdk.CachePath("parallels-source-" + hex.EncodeToString(sum[:]))
+		if err != nil {
+			err = fmt.Errorf("Error creating cache directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		dstDir = cacheDir
+	} else {
+		tempDir, err := tmp.Dir("packer-source")
+		if err != nil {
+			err = fmt.Errorf("Error creating temporary directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.tempDir = tempDir
+		dstDir = tempDir
+	}
+
+	if _, err := os.Stat(filepath.Join(dstDir, extractedMarker)); err == nil {
+		ui.Say(fmt.Sprintf("Using the extracted source VM from the cache: %s", dstDir))
+	} else {
+		ui.Say(fmt.Sprintf("Extracting source VM archive: %s", archivePath))
+		extract := s.extract
+		if s.tempDir != "" {
+			// The temporary directory is private to the build
+			extract = extractSourceArchive
+		}
+		if err := extract(archivePath, dstDir); err != nil {
+			err = fmt.Errorf("Error extracting source VM archive: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	sourcePath, err := findVMBundle(dstDir)
+	if err != nil {
+		err = fmt.Errorf("Error extracting source VM archive: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("Extracted source VM: %s", sourcePath)
+	state.Put("source_path", sourcePath)
+	return multistep.ActionContinue
+}
+
+// extract unpacks the archive into a unique directory next to its
+// destination first, so that an interrupted extraction never leaves a
+// half-populated cache behind, and the builds extracting the same archive at
+// once don't overwrite each other. The destination extracted by another
+// build in the meantime is used as is.
+func (s *StepExtractSource) extract(archivePath, dstDir string) error {
+	partialDir, err := os.MkdirTemp(filepath.Dir(dstDir), filepath.Base(dstDir)+".partial-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(partialDir)
+
+	if err := extractSourceArchive(archivePath, partialDir); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(partialDir, extractedMarker))
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	// The rename fails if the destination exists, extracted by another build
+	if err := os.Rename(partialDir, dstDir); err != nil {
+		if _, statErr := os.Stat(filepath.Join(dstDir, extractedMarker)); statErr == nil {
+			log.Printf("Source VM extracted by another build: %s", dstDir)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes the extracted VM unless it is cached.
+func (s *StepExtractSource) Cleanup(state multistep.StateBag) {
+	if s.tempDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(s.tempDir); err != nil {
+		log.Printf("Error removing extracted source VM: %s", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepExtractSource_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExtractSource)
+}
+
+func testSourceArchive(t *testing.T) string {
+	archivePath := filepath.Join(t.TempDir(), "source.tar.gz")
+	tarball := testTarball(t, map[string]string{"ubuntu.pvm/config.pvs": "<ParallelsVirtualMachine/>"})
+	if err := os.WriteFile(archivePath, testCompress(t, "gzip", tarball), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return archivePath
+}
+
+func TestStepExtractSource(t *testing.T) {
+	state := testState(t)
+	step := new(StepExtractSource)
+
+	state.Put("source_archive_path", testSourceArchive(t))
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	sourcePath := state.Get("source_path").(string)
+	if _, err := os.Stat(filepath.Join(sourcePath, "config.pvs")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test the cleanup
+	step.Cleanup(state)
+	if _, err := os.Stat(sourcePath); err == nil {
+		t.Fatal("extracted VM should be removed")
+	}
+}
+
+func TestStepExtractSource_cache(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+
+	state := testState(t)
+	step := &StepExtractSource{Checksum: testSourceChecksum}
+
+	archivePath := testSourceArchive(t)
+	state.Put("source_archive_path", archivePath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	sourcePath := state.Get("source_path").(string)
+	if _, err := os.Stat(filepath.Join(sourcePath, "config.pvs")); err != nil {
+		t.Fatalf("cached VM should be kept: %s", err)
+	}
+
+	// The second run must reuse the cache, even without the archive
+	if err := os.Remove(archivePath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state = testState(t)
+	state.Put("source_archive_path", archivePath)
+	step = &StepExtractSource{Checksum: testSourceChecksum}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("source_path").(string) != sourcePath {
+		t.Fatalf("bad: %s", state.Get("source_path"))
+	}
+}
+
+func TestStepExtractSource_concurrentCache(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	archivePath := testSourceArchive(t)
+
+	// The builds extracting the same archive at once share the cache
+	const builds = 4
+	var wg sync.WaitGroup
+	states := make([]multistep.StateBag, builds)
+	for i := range states {
+		states[i] = testState(t)
+		states[i].Put("source_archive_path", archivePath)
+
+		wg.Add(1)
+		go func(state multistep.StateBag) {
+			defer wg.Done()
+			step := &StepExtractSource{Checksum: testSourceChecksum}
+			step.Run(context.Background(), state)
+		}(states[i])
+	}
+	wg.Wait()
+
+	sourcePath := ""
+	for _, state := range states {
+		if err, ok := state.GetOk("error"); ok {
+			t.Fatalf("err: %s", err)
+		}
+		path := state.Get("source_path").(string)
+		if sourcePath != "" && path != sourcePath {
+			t.Fatalf("bad: %s, expected: %s", path, sourcePath)
+		}
+		sourcePath = path
+	}
+	if _, err := os.Stat(filepath.Join(sourcePath, "config.pvs")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// No partial extraction is left behind
+	entries, err := filepath.Glob(filepath.Join(filepath.Dir(filepath.Dir(sourcePath)), "*.partial-*"))
+	if err != nil || len(entries) > 0 {
+		t.Fatalf("bad: %#v, %v", entries, err)
+	}
+}
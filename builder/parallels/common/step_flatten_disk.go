@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepFlattenDisk is a step that turns a linked clone into a standalone VM
+// by merging the data of its parent into every virtual disk.
+//
+// Uses:
+//
+//	driver Driver
+//	linked_clone_parent string
+//	linked_clone_snapshot linkedCloneSnapshot
+//	vmName string
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type StepFlattenDisk struct {
+	Skip bool
+}
+
+// Run merges the parent's data into the virtual disks attached to the VM.
+func (s *StepFlattenDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.Skip {
+		return multistep.ActionContinue
+	}
+
+	parent, ok := state.GetOk("linked_clone_parent")
+	if !ok {
+		ui.Say("VM is not a linked clone, skipping flatten step...")
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("Flattening the linked clone of %s", parent))
+	// Every disk of a linked clone is linked to the parent
+	diskPaths, err := driver.DiskPaths(vmName)
+	if err != nil {
+		err = fmt.Errorf("Error detecting virtual disk paths: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	for _, diskPath := range diskPaths {
+		if err := driver.FlattenDisk(diskPath); err != nil {
+			err = fmt.Errorf("Error flattening disk %s: %s", diskPath, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// The VM no longer depends on its parent
+	state.Remove("linked_clone_parent")
+
+	// Nor on the snapshot of its parent taken for the linked clone
+	deleteLinkedCloneSnapshot(driver, ui, state)
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing.
+func (*StepFlattenDisk) Cleanup(multistep.StateBag) {}
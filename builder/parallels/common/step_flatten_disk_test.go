@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepFlattenDisk_impl(t *testing.T) {
+	var _ multistep.Step = new(StepFlattenDisk)
+}
+
+func TestStepFlattenDisk(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+
+	state.Put("vmName", "foo")
+	state.Put("linked_clone_parent", "/path/to/parent.pvm")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Mock results
+	driver.DiskPathsResult = []string{"/path/to/harddisk.hdd"}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test the driver
+	if !driver.FlattenDiskCalled {
+		t.Fatal("should've called")
+	}
+
+	if driver.FlattenDiskPath != "/path/to/harddisk.hdd" {
+		t.Fatalf("bad path: %s", driver.FlattenDiskPath)
+	}
+
+	if _, ok := state.GetOk("linked_clone_parent"); ok {
+		t.Fatal("should not depend on the parent anymore")
+	}
+}
+
+func TestStepFlattenDisk_snapshot(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+
+	state.Put("vmName", "foo")
+	state.Put("linked_clone_parent", "/path/to/parent.pvm")
+	state.Put("linked_clone_snapshot", linkedCloneSnapshot{
+		VM: "/path/to/parent.pvm",
+		ID: "{1d1c1b1a-0000-0000-0000-000000000000}",
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DiskPathsResult = []string{"/path/to/harddisk.hdd"}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// The snapshot taken for the linked clone is no longer needed
+	if driver.DeleteSnapshotVM != "/path/to/parent.pvm" || driver.DeleteSnapshotID != "{1d1c1b1a-0000-0000-0000-000000000000}" {
+		t.Fatalf("bad snapshot: %s %s", driver.DeleteSnapshotVM, driver.DeleteSnapshotID)
+	}
+	if _, ok := state.GetOk("linked_clone_snapshot"); ok {
+		t.Fatal("should not have the snapshot anymore")
+	}
+}
+
+func TestStepFlattenDisk_notLinked(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Test the driver
+	if driver.FlattenDiskCalled {
+		t.Fatal("should not have called")
+	}
+}
+
+func TestStepFlattenDisk_skip(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+	step.Skip = true
+
+	state.Put("vmName", "foo")
+	state.Put("linked_clone_parent", "/path/to/parent.pvm")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Test the driver
+	if driver.FlattenDiskCalled {
+		t.Fatal("should not have called")
+	}
+
+	if _, ok := state.GetOk("linked_clone_parent"); !ok {
+		t.Fatal("should still depend on the parent")
+	}
+}
+
+func TestStepFlattenDisk_disks(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+
+	state.Put("vmName", "foo")
+	state.Put("linked_clone_parent", "/path/to/parent.pvm")
+	state.Put("linked_clone_snapshot", linkedCloneSnapshot{
+		VM: "/path/to/parent.pvm",
+		ID: "{1d1c1b1a-0000-0000-0000-000000000000}",
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DiskPathsResult = []string{"/path/to/harddisk.hdd", "/path/to/harddisk1.hdd"}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Every disk is flattened before the snapshot is deleted
+	if !reflect.DeepEqual(driver.FlattenDiskPaths, driver.DiskPathsResult) {
+		t.Fatalf("bad paths: %#v", driver.FlattenDiskPaths)
+	}
+	if !driver.DeleteSnapshotCalled {
+		t.Fatal("should've deleted the snapshot")
+	}
+}
+
+func TestStepFlattenDisk_diskError(t *testing.T) {
+	state := testState(t)
+	step := new(StepFlattenDisk)
+
+	state.Put("vmName", "foo")
+	state.Put("linked_clone_parent", "/path/to/parent.pvm")
+	state.Put("linked_clone_snapshot", linkedCloneSnapshot{
+		VM: "/path/to/parent.pvm",
+		ID: "{1d1c1b1a-0000-0000-0000-000000000000}",
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DiskPathsResult = []string{"/path/to/harddisk.hdd", "/path/to/harddisk1.hdd"}
+	driver.FlattenDiskErr = errors.New("merge failed")
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// The disks still depend on the snapshot
+	if driver.DeleteSnapshotCalled {
+		t.Fatal("should not have deleted the snapshot")
+	}
+	if _, ok := state.GetOk("linked_clone_parent"); !ok {
+		t.Fatal("should still depend on the parent")
+	}
+}
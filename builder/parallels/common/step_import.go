@@ -6,48 +6,189 @@ package common
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// This step imports an PVM VM into Parallels.
+// This step imports an PVM VM into Parallels. If SourceVM is set, a VM
+// already registered in Parallels Desktop is cloned instead, and it is never
+// unregistered. If both SourcePath and SourceVM are empty, the VM extracted
+// from the source archive is imported.
+//
+// Uses:
+//
+//	source_path string - only if SourcePath and SourceVM are empty
+//
+// Produces:
+//
+//	vmName string - The name of the VM
+//	linked_clone_parent string - The path to the parent VM, if a linked clone
+//	  was created
+//	linked_clone_snapshot linkedCloneSnapshot - The snapshot of the source VM
+//	  taken for the linked clone, if any
 type StepImport struct {
-	Name        string
-	SourcePath  string
-	vmName      string
-	OutputDir   string
-	ReassignMAC bool
+	Name           string
+	SourcePath     string
+	SourceVM       string
+	SourceSnapshot string
+	vmName         string
+	snapshot       *linkedCloneSnapshot
+	OutputDir      string
+	ReassignMAC    bool
+	LinkedClone    bool
+	KeepRegistered bool
 }
 
 func (s *StepImport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	ui.Say(fmt.Sprintf("Importing VM: %s", s.SourcePath))
-	if err := driver.Import(s.Name, s.SourcePath, s.OutputDir, s.ReassignMAC); err != nil {
+	var parentPath string
+	var err error
+	if s.SourceVM != "" {
+		parentPath, err = s.cloneRegistered(driver, ui)
+	} else {
+		parentPath, err = s.importPath(driver, ui, state)
+	}
+	if s.snapshot != nil {
+		state.Put("linked_clone_snapshot", *s.snapshot)
+	}
+	if err != nil {
 		err := fmt.Errorf("Error importing VM: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
+	// The imported VM has its original settings, which may be restored at
+	// the end of the build.
+	CaptureVMSettings(state, driver, s.Name)
+
+	if s.LinkedClone {
+		// The resulting VM can't be used without its parent, so it has to be
+		// recorded in the artifact.
+		state.Put("linked_clone_parent", parentPath)
+	}
+
 	s.vmName = s.Name
 	state.Put("vmName", s.Name)
 	return multistep.ActionContinue
 }
 
+// importPath registers the source VM from its path, clones it and
+// unregisters it again. It returns the absolute path to the source VM.
+func (s *StepImport) importPath(driver Driver, ui packersdk.Ui, state multistep.StateBag) (string, error) {
+	sourcePath := s.SourcePath
+	if sourcePath == "" {
+		sourcePath = state.Get("source_path").(string)
+	}
+
+	ui.Say(fmt.Sprintf("Importing VM: %s", sourcePath))
+	if s.LinkedClone {
+		ui.Message("Creating a linked clone of the source VM")
+	}
+	parentPath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		parentPath = sourcePath
+	}
+
+	snapshotID, err := driver.Import(s.Name, sourcePath, s.OutputDir, s.ReassignMAC, s.LinkedClone)
+	if snapshotID != "" {
+		s.snapshot = &linkedCloneSnapshot{VM: parentPath, ID: snapshotID}
+	}
+	if err != nil {
+		return "", err
+	}
+	return parentPath, nil
+}
+
+// cloneRegistered clones a VM or template which is already registered in
+// Parallels Desktop. It returns the path to the source VM.
+func (s *StepImport) cloneRegistered(driver Driver, ui packersdk.Ui) (string, error) {
+	vm, err := driver.LookupVM(s.SourceVM)
+	if err != nil {
+		return "", err
+	}
+
+	if !vm.Template && vm.State != "stopped" {
+		return "", fmt.Errorf("source VM %q is %s, it must be stopped to be cloned", vm.Name, vm.State)
+	}
+
+	snapshotID := ""
+	if s.SourceSnapshot != "" {
+		snapshotID, err = driver.SnapshotID(vm.ID, s.SourceSnapshot)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Cloning VM: %s (%s)", vm.Name, vm.ID))
+	if s.LinkedClone {
+		if snapshotID != "" {
+			ui.Message(fmt.Sprintf("Creating a linked clone of the snapshot: %s", snapshotID))
+		} else {
+			ui.Message("Creating a linked clone of the source VM")
+		}
+	}
+	newSnapshotID, err := driver.Clone(s.Name, vm.ID, s.OutputDir, snapshotID, s.ReassignMAC, s.LinkedClone)
+	if newSnapshotID != "" {
+		s.snapshot = &linkedCloneSnapshot{VM: vm.ID, ID: newSnapshotID}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return vm.Home, nil
+}
+
 func (s *StepImport) Cleanup(state multistep.StateBag) {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// The snapshot taken for the linked clone of a failed build is of no
+	// use once the clone is unregistered
+	if s.snapshot != nil && !KeepVMRegistered(state, true) {
+		defer deleteLinkedCloneSnapshot(driver, ui, state)
+	}
 
 	if s.vmName == "" {
 		return
 	}
 
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
+	if KeepVMRegistered(state, s.KeepRegistered) {
+		ui.Say(fmt.Sprintf("Keeping the virtual machine registered: %s", s.vmName))
+		return
+	}
 
 	ui.Say("Unregistering virtual machine...")
 	if err := driver.Prlctl("unregister", s.vmName); err != nil {
 		ui.Error(fmt.Sprintf("Error unregistering virtual machine: %s", err))
 	}
 }
+
+// linkedCloneSnapshot is the snapshot of the source VM taken by the build
+// for a linked clone.
+type linkedCloneSnapshot struct {
+	// The ID of the source VM if it is registered, its path otherwise
+	VM string
+	ID string
+}
+
+// deleteLinkedCloneSnapshot deletes the snapshot of the source VM taken by
+// the build for the linked clone, if any, once the clone no longer depends
+// on it. A failure is reported but doesn't fail the build.
+func deleteLinkedCloneSnapshot(driver Driver, ui packersdk.Ui, state multistep.StateBag) {
+	raw, ok := state.GetOk("linked_clone_snapshot")
+	if !ok {
+		return
+	}
+	snapshot := raw.(linkedCloneSnapshot)
+
+	ui.Say(fmt.Sprintf("Deleting the snapshot of the source VM taken for the linked clone: %s", snapshot.ID))
+	if err := driver.DeleteSnapshot(snapshot.VM, snapshot.ID); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting the snapshot %s of %s: %s", snapshot.ID, snapshot.VM, err))
+	}
+	state.Remove("linked_clone_snapshot")
+}
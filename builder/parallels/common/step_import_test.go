@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepImport_impl(t *testing.T) {
+	var _ multistep.Step = new(StepImport)
+}
+
+func TestStepImport_sourceVM(t *testing.T) {
+	state := testState(t)
+	step := &StepImport{
+		Name:           "foo",
+		SourceVM:       "base",
+		SourceSnapshot: "clean",
+		OutputDir:      "/path/to/output",
+		LinkedClone:    true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:    "{5f2c2b1a-0000-0000-0000-000000000000}",
+		Name:  "base",
+		State: "stopped",
+		Home:  "/path/to/base.pvm",
+	}
+	driver.SnapshotIDResult = "{1d1c1b1a-0000-0000-0000-000000000000}"
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test the driver
+	if driver.ImportCalled {
+		t.Fatal("should not register the source VM")
+	}
+	if !driver.CloneCalled {
+		t.Fatal("should've called")
+	}
+	if driver.CloneSrcID != driver.LookupVMResult.ID {
+		t.Fatalf("bad source: %s", driver.CloneSrcID)
+	}
+	if driver.CloneSnapshotID != driver.SnapshotIDResult {
+		t.Fatalf("bad snapshot: %s", driver.CloneSnapshotID)
+	}
+	if parent := state.Get("linked_clone_parent").(string); parent != "/path/to/base.pvm" {
+		t.Fatalf("bad parent: %s", parent)
+	}
+
+	// Only the clone is unregistered
+	step.Cleanup(state)
+	if len(driver.PrlctlCalls) != 1 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+	if call := driver.PrlctlCalls[0]; call[0] != "unregister" || call[1] != "foo" {
+		t.Fatalf("bad call: %#v", call)
+	}
+}
+
+func TestStepImport_sourceVMRunning(t *testing.T) {
+	state := testState(t)
+	step := &StepImport{
+		Name:     "foo",
+		SourceVM: "base",
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:    "{5f2c2b1a-0000-0000-0000-000000000000}",
+		Name:  "base",
+		State: "running",
+	}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if driver.CloneCalled {
+		t.Fatal("should not clone a running VM")
+	}
+
+	// Nothing to unregister
+	step.Cleanup(state)
+	if len(driver.PrlctlCalls) != 0 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestStepImport_keepRegistered(t *testing.T) {
+	state := testState(t)
+	step := &StepImport{
+		Name:           "foo",
+		SourcePath:     "/path/to/source.pvm",
+		KeepRegistered: true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// A successful build keeps the VM
+	step.Cleanup(state)
+	if len(driver.PrlctlCalls) != 0 {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+
+	// A failed build doesn't
+	state.Put("error", errors.New("failed"))
+	step.Cleanup(state)
+	if len(driver.PrlctlCalls) != 1 || driver.PrlctlCalls[0][0] != "unregister" {
+		t.Fatalf("bad calls: %#v", driver.PrlctlCalls)
+	}
+}
+
+func TestStepImport_linkedCloneSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepImport{
+		Name:        "foo",
+		SourceVM:    "base",
+		LinkedClone: true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:    "{5f2c2b1a-0000-0000-0000-000000000000}",
+		Name:  "base",
+		State: "stopped",
+		Home:  "/path/to/base.pvm",
+	}
+	driver.CreatedSnapshotID = "{1d1c1b1a-0000-0000-0000-000000000000}"
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	snapshot, ok := state.Get("linked_clone_snapshot").(linkedCloneSnapshot)
+	if !ok || snapshot.VM != driver.LookupVMResult.ID || snapshot.ID != driver.CreatedSnapshotID {
+		t.Fatalf("bad snapshot: %#v", state.Get("linked_clone_snapshot"))
+	}
+
+	// The linked clone of a successful build depends on the snapshot
+	step.Cleanup(state)
+	if driver.DeleteSnapshotCalled {
+		t.Fatal("should not delete the snapshot")
+	}
+
+	// The snapshot of a failed build is deleted
+	state.Put("error", errors.New("failed"))
+	step.Cleanup(state)
+	if !driver.DeleteSnapshotCalled {
+		t.Fatal("should delete the snapshot")
+	}
+	if driver.DeleteSnapshotVM != snapshot.VM || driver.DeleteSnapshotID != snapshot.ID {
+		t.Fatalf("bad snapshot: %s %s", driver.DeleteSnapshotVM, driver.DeleteSnapshotID)
+	}
+}
+
+func TestStepImport_linkedCloneSourceSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepImport{
+		Name:           "foo",
+		SourceVM:       "base",
+		SourceSnapshot: "clean",
+		LinkedClone:    true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.LookupVMResult = &RegisteredVM{
+		ID:    "{5f2c2b1a-0000-0000-0000-000000000000}",
+		Name:  "base",
+		State: "stopped",
+	}
+	driver.SnapshotIDResult = "{1d1c1b1a-0000-0000-0000-000000000000}"
+
+	// The snapshot given by the user is never deleted
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("linked_clone_snapshot"); ok {
+		t.Fatal("should not have taken a snapshot")
+	}
+
+	state.Put("error", errors.New("failed"))
+	step.Cleanup(state)
+	if driver.DeleteSnapshotCalled {
+		t.Fatal("should not delete the snapshot")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-version"
@@ -23,13 +25,27 @@ const macScreenCaptureBinaryPath = "/usr/sbin/screencapture"
 // This step creates the virtual disk that will be used as the
 // hard drive for the virtual machine.
 type StepScreenBasedBoot struct {
-	ScreenConfigs map[string]BootScreenConfig
-	OCRLibrary    string
-	VmName        string
-	Ctx           interpolate.Context
+	ScreenConfigs  []BootScreenConfig
+	OCR            OCRConfig
+	VmName         string
+	Ctx            interpolate.Context
+	KeyboardLayout string
+	KeyInterval    time.Duration
+	// The maximum duration until the last screen is identified, 0 for no
+	// timeout.
+	Timeout time.Duration
+	// The directory keeping the distinct screenshots, if not empty.
+	ScreenshotsDir string
+	// The file the screens are recorded to, as boot_screen_config, while the
+	// operator drives the VM, if not empty.
+	RecordFile string
+	// Whether the VM is a macOS VM, whose window is captured prior to
+	// Parallels Desktop 20. The screen of the other VMs is always captured
+	// with 'prlctl capture'.
+	MacVM bool
 }
 
-func (s *StepScreenBasedBoot) executeBootCommand(bootConfig bootcommand.BootConfig, ctx context.Context, state multistep.StateBag) error {
+func (s *StepScreenBasedBoot) executeBootCommand(bootConfig bootcommand.BootConfig, texts ScreenTexts, ctx context.Context, state multistep.StateBag) error {
 	// Execute the boot command
 	step := StepTypeBootCommand{
 		BootWait:       -1,
@@ -38,6 +54,11 @@ func (s *StepScreenBasedBoot) executeBootCommand(bootConfig bootcommand.BootConf
 		VMName:         s.VmName,
 		Ctx:            s.Ctx,
 		GroupInterval:  bootConfig.BootGroupInterval,
+		KeyboardLayout: s.KeyboardLayout,
+		KeyInterval:    s.KeyInterval,
+		OCR:            s.OCR,
+		Texts:          texts,
+		MacVM:          s.MacVM,
 	}
 
 	resultAction := step.Run(ctx, state)
@@ -53,12 +74,12 @@ func (s *StepScreenBasedBoot) executeBootCommand(bootConfig bootcommand.BootConf
 	return errors.New("boot command failed")
 }
 
-func (s *StepScreenBasedBoot) captureScreenPD(state multistep.StateBag, fileName string) error {
+func captureScreenPD(state multistep.StateBag, vmName string, fileName string) error {
 	driver := state.Get("driver").(Driver)
-	return driver.Prlctl("capture", s.VmName, "--file", fileName)
+	return driver.Prlctl("capture", vmName, "--file", fileName)
 }
 
-func (s *StepScreenBasedBoot) captureScreenMac(windowId string, fileName string) error {
+func captureScreenMac(windowId string, fileName string) error {
 	// Window ID option
 	windowIDOption := "-l" + fmt.Sprint(windowId)
 	// Command-line arguments to pass to the binary
@@ -67,39 +88,70 @@ func (s *StepScreenBasedBoot) captureScreenMac(windowId string, fileName string)
 	return err
 }
 
-func (s *StepScreenBasedBoot) captureScreen(state multistep.StateBag, windowId string, fileName string) error {
+// captureScreen saves a screenshot of the VM to the file.
+func captureScreen(state multistep.StateBag, vmName string, windowId string, fileName string) error {
 	if windowId == "-1" { // PD version is above 20.0.0
-		return s.captureScreenPD(state, fileName)
+		return captureScreenPD(state, vmName, fileName)
 	}
-	return s.captureScreenMac(windowId, fileName)
+	return captureScreenMac(windowId, fileName)
 }
 
-func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	// We don't have any screen configs, so no wasting of time here.
-	if (s.ScreenConfigs == nil) || (len(s.ScreenConfigs) == 0) {
-		return multistep.ActionContinue
+// capturesWithPrlctl returns whether the screen of the VM is captured by
+// 'prlctl capture', rather than as a window of the host.
+func capturesWithPrlctl(state multistep.StateBag, macVM bool) (bool, error) {
+	if !macVM {
+		return true, nil
 	}
 
 	driver := state.Get("driver").(Driver)
 	prlctlCurrVersionStr, verErr := driver.Version()
 	if verErr != nil {
-		log.Println("Error retrieving prlctl version:", verErr)
-		return multistep.ActionHalt
+		return false, fmt.Errorf("error retrieving prlctl version: %s", verErr)
+	}
+	prlctlCurrVersion, verErr := version.NewVersion(prlctlCurrVersionStr)
+	if verErr != nil {
+		return false, fmt.Errorf("error parsing prlctl version: %s", verErr)
 	}
-	prlctlCurrVersion, _ := version.NewVersion(prlctlCurrVersionStr)
 	v2, _ := version.NewVersion("20.0.0")
 
 	// From PD20.0.0, 'prlctl capture' command is available for macOS VMs
-	windowId := -1
-	if prlctlCurrVersion.LessThan(v2) {
-		// Retrieve the window ID
-		windowIDDetector := WindowIDDetector{}
-		var err error
-		windowId, err = windowIDDetector.DetectWindowId(s.VmName, state)
-		if err != nil || windowId == 0 {
-			log.Println("Error retrieving window ID:", err)
-			return multistep.ActionHalt
-		}
+	return !prlctlCurrVersion.LessThan(v2), nil
+}
+
+// detectCaptureWindowId returns the ID of the window of the VM to capture, or
+// -1 if the screen is captured by 'prlctl capture'.
+func detectCaptureWindowId(state multistep.StateBag, vmName string, macVM bool) (int, error) {
+	prlctl, err := capturesWithPrlctl(state, macVM)
+	if err != nil {
+		return 0, err
+	}
+	if prlctl {
+		return -1, nil
+	}
+
+	// Retrieve the window ID
+	windowIDDetector := WindowIDDetector{}
+	windowId, err := windowIDDetector.DetectWindowId(vmName, state)
+	if err != nil || windowId == 0 {
+		return 0, fmt.Errorf("error retrieving window ID: %v", err)
+	}
+	return windowId, nil
+}
+
+func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.RecordFile != "" {
+		return s.record(ctx, state, s.RecordFile)
+	}
+
+	// We don't have any screen configs, so no wasting of time here.
+	if (s.ScreenConfigs == nil) || (len(s.ScreenConfigs) == 0) {
+		return multistep.ActionContinue
+	}
+
+	windowId, err := detectCaptureWindowId(state, s.VmName, s.MacVM)
+	if err != nil {
+		log.Println("Error:", err)
+		return multistep.ActionHalt
 	}
 
 	ui := state.Get("ui").(packersdk.Ui)
@@ -115,9 +167,38 @@ func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag)
 	ui.Say("Starting screen based boot...")
 
 	prevTime := time.Now()
-	minDelay := 1 * time.Second
+	poller := newScreenPoller()
+	defer func() {
+		log.Printf("Screen based boot: %d OCR calls, %d skipped as the screen was unchanged", poller.ocrCalls, poller.skippedCalls)
+	}()
 	lastScreen := BootScreenConfig{}
-	ocrWrapper, _ := NewOCRWrapper(s.OCRLibrary, s.ScreenConfigs)
+	ocrWrapper, err := NewOCRWrapper(s.OCR, s.ScreenConfigs)
+	if err != nil {
+		log.Println("Error:", err)
+		return multistep.ActionHalt
+	}
+
+	var recorder *screenshotRecorder
+	if s.ScreenshotsDir != "" {
+		recorder, err = newScreenshotRecorder(s.ScreenshotsDir)
+		if err != nil {
+			err := fmt.Errorf("Error creating the screenshots directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer writeScreenshotsGIF(ui, recorder)
+	}
+
+	// When the screen was identified first, and how many times it showed up
+	startTime := time.Now()
+	screenTime := time.Now()
+	screenCounts := make(map[string]int)
+	// The path through the screens, checked against their next_screens
+	path := newScreenPath(newScreenGraph(s.ScreenConfigs))
+	// The screen identified in the last recognized screenshot
+	var screenConfig BootScreenConfig
+	var texts ScreenTexts
 	for {
 		log.Println("Checking screen...")
 
@@ -126,33 +207,68 @@ func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag)
 			return multistep.ActionHalt
 		}
 
-		// Check if the minimum delay has passed
-		if time.Since(prevTime) < minDelay {
-			time.Sleep(minDelay - time.Since(prevTime))
+		// Check if the polling interval has passed
+		if time.Since(prevTime) < poller.interval {
+			time.Sleep(poller.interval - time.Since(prevTime))
 		}
 		prevTime = time.Now()
 
 		// Capturing the screenshot
-		err := s.captureScreen(state, fmt.Sprint(windowId), file.Name())
+		err := captureScreen(state, s.VmName, fmt.Sprint(windowId), file.Name())
 		if err != nil {
 			log.Println("Error: '", err, "' while capturing the screenshot.")
 			ui.Error("Error capturing the screenshot. Make sure you have the necessary permissions.")
 			return multistep.ActionHalt
 		}
 
-		screenConfig, err := ocrWrapper.IdentifyCurrentScreen(file.Name())
-		if err != nil {
-			log.Println("Error:", err)
-			return multistep.ActionHalt
+		if poller.unchanged(file.Name(), prevTime) {
+			log.Println("Screen unchanged, skipping OCR")
+		} else {
+			screenConfig, texts, err = ocrWrapper.IdentifyCurrentScreen(file.Name())
+			if err != nil {
+				log.Println("Error:", err)
+				return multistep.ActionHalt
+			}
+
+			if recorder != nil {
+				if err := recorder.record(file.Name(), texts, screenConfig.ScreenName); err != nil {
+					log.Println("Error recording the screenshot:", err)
+				}
+			}
+		}
+
+		if screenConfig.IsFailureScreen {
+			err := fmt.Errorf("failure screen %q identified", screenConfig.ScreenName)
+			return s.halt(state, ocrWrapper, file.Name(), err)
+		}
+
+		if s.Timeout > 0 && time.Since(startTime) > s.Timeout {
+			err := fmt.Errorf("timeout after %s waiting for the last screen, the screen is %q", s.Timeout, screenConfig.ScreenName)
+			return s.halt(state, ocrWrapper, file.Name(), err)
+		}
+
+		if screenConfig.ScreenName != lastScreen.ScreenName {
+			screenTime = time.Now()
+			screenCounts[screenConfig.ScreenName]++
+			if screenConfig.MaxRepeats > 0 && screenCounts[screenConfig.ScreenName] > screenConfig.MaxRepeats {
+				err := fmt.Errorf("screen %q showed up more than %d times", screenConfig.ScreenName, screenConfig.MaxRepeats)
+				return s.halt(state, ocrWrapper, file.Name(), err)
+			}
+			if err := path.visit(screenConfig); err != nil {
+				return s.halt(state, ocrWrapper, file.Name(), err)
+			}
+		} else if screenConfig.Timeout > 0 && time.Since(screenTime) > screenConfig.Timeout {
+			err := fmt.Errorf("screen %q is still on after %s", screenConfig.ScreenName, screenConfig.Timeout)
+			return s.halt(state, ocrWrapper, file.Name(), err)
 		}
 
 		// If the screen is the same as the last screen and the last screen is not empty, skip the boot command
-		if screenConfig.ScreenName == lastScreen.ScreenName && len(lastScreen.MatchingStrings) > 0 {
+		if screenConfig.ScreenName == lastScreen.ScreenName && !lastScreen.IsEmpty() {
 			continue
 		}
 
 		// If we switched the screen & the last screen is execute only once, then remove the screen
-		if len(lastScreen.MatchingStrings) > 0 && lastScreen.ExecuteOnlyOnce {
+		if !lastScreen.IsEmpty() && lastScreen.ExecuteOnlyOnce {
 			ocrWrapper.RemoveBootScreenConfigIfExist(lastScreen.ScreenName)
 		}
 
@@ -160,12 +276,18 @@ func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag)
 		ui.Say("Screen changed to " + screenConfig.ScreenName)
 		// Execute the boot command
 		bootCommand := screenConfig.FlatBootCommand()
+		if recorder != nil {
+			if err := recorder.recordBootCommand(bootCommand); err != nil {
+				log.Println("Error recording the boot command:", err)
+			}
+		}
 		if bootCommand != "" {
-			err := s.executeBootCommand(screenConfig.BootConfig, ctx, state)
+			err := s.executeBootCommand(screenConfig.BootConfig, texts, ctx, state)
 			if err != nil {
 				log.Println("Error:", err)
 				return multistep.ActionHalt
 			}
+			poller.typed()
 		}
 
 		if screenConfig.IsLastScreen {
@@ -177,4 +299,140 @@ func (s *StepScreenBasedBoot) Run(ctx context.Context, state multistep.StateBag)
 	return multistep.ActionContinue
 }
 
+// record captures the screens while the operator drives the VM, until they
+// answer, and writes the proposed boot_screen_config of the distinct screens
+// to the file.
+func (s *StepScreenBasedBoot) record(ctx context.Context, state multistep.StateBag, recordFile string) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	halt := func(err error) multistep.StepAction {
+		err = fmt.Errorf("Error recording the boot screens: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	windowId, err := detectCaptureWindowId(state, s.VmName, s.MacVM)
+	if err != nil {
+		return halt(err)
+	}
+	ocrWrapper, err := NewOCRWrapper(s.OCR, nil)
+	if err != nil {
+		return halt(err)
+	}
+	file, err := os.CreateTemp("", "screenshot*.png")
+	if err != nil {
+		return halt(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	recorder := &bootScreenRecorder{}
+	poller := newScreenPoller()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(poller.interval):
+			}
+
+			if err := captureScreen(state, s.VmName, fmt.Sprint(windowId), file.Name()); err != nil {
+				log.Println("Error capturing the screenshot:", err)
+				continue
+			}
+			if poller.unchanged(file.Name(), time.Now()) {
+				continue
+			}
+			texts, err := ocrWrapper.RecognizeText(file.Name())
+			if err != nil {
+				log.Println("Error recognizing the text of the screenshot:", err)
+				continue
+			}
+			screenTexts, err := locateImageTexts(texts, file.Name())
+			if err != nil {
+				log.Println("Error recognizing the text of the screenshot:", err)
+				continue
+			}
+			// The UI is busy asking the operator
+			if recorder.add(poller.hash, screenTexts) {
+				log.Printf("Recorded screen %d: %s", len(recorder.screens), recorder.last.title)
+			}
+		}
+	}()
+
+	ui.Say("Recording the boot screens, drive the VM in its window...")
+	_, err = ui.Ask("Press enter once done to write the boot screen config")
+	close(done)
+	<-stopped
+	if err != nil {
+		return halt(err)
+	}
+	log.Printf("Recording the boot screens: %d OCR calls, %d skipped as the screen was unchanged", poller.ocrCalls, poller.skippedCalls)
+
+	if len(recorder.screens) == 0 {
+		return halt(errors.New("no screen captured"))
+	}
+	if err := recorder.writeHCL(recordFile); err != nil {
+		return halt(err)
+	}
+	ui.Say(fmt.Sprintf("Boot screen config of %d screens written to %s", len(recorder.screens), recordFile))
+	return multistep.ActionContinue
+}
+
+// writeScreenshotsGIF assembles the recorded screenshots into an animated GIF.
+func writeScreenshotsGIF(ui packersdk.Ui, recorder *screenshotRecorder) {
+	path, err := recorder.writeGIF()
+	if err != nil {
+		log.Println("Error writing the animated GIF of the screenshots:", err)
+		return
+	}
+	ui.Say(fmt.Sprintf("Screenshots of the boot saved to %s", path))
+}
+
+// halt fails the screen based boot with the error. The last screenshot and
+// its text are kept for debugging, their paths being part of the error.
+func (s *StepScreenBasedBoot) halt(state multistep.StateBag, ocrWrapper OCRWrapper, fileName string, err error) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	screenshot, saveErr := keepScreenshot(fileName)
+	if saveErr != nil {
+		log.Println("Error saving the last screenshot:", saveErr)
+	} else {
+		err = fmt.Errorf("%s, last screenshot: %s", err, screenshot)
+
+		textFile := strings.TrimSuffix(screenshot, filepath.Ext(screenshot)) + ".txt"
+		if saveErr := saveScreenText(ocrWrapper, fileName, textFile); saveErr != nil {
+			log.Println("Error saving the text of the last screenshot:", saveErr)
+		} else {
+			err = fmt.Errorf("%s, its text: %s", err, textFile)
+		}
+	}
+
+	err = fmt.Errorf("Error running the screen based boot: %s", err)
+	state.Put("error", err)
+	ui.Error(err.Error())
+	return multistep.ActionHalt
+}
+
+// saveScreenText writes the lines of text recognized in the screenshot to the
+// file.
+func saveScreenText(ocrWrapper OCRWrapper, fileName string, textFile string) error {
+	texts, err := ocrWrapper.RecognizeText(fileName)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, text := range texts {
+		lines = append(lines, text.Text)
+	}
+	return os.WriteFile(textFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
 func (s *StepScreenBasedBoot) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,173 @@
+// Copyright (c) Parallels International GmBH
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ocrWrapperMock is an OCRWrapper recognizing the same text in any image.
+type ocrWrapperMock struct {
+	screen BootScreenConfig
+	texts  []OCRText
+}
+
+func (c *ocrWrapperMock) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	return c.screen, locateTexts(c.texts, 0, 0), nil
+}
+
+func (c *ocrWrapperMock) RemoveBootScreenConfigIfExist(screenName string) {}
+
+func (c *ocrWrapperMock) RecognizeText(imagePath string) ([]OCRText, error) {
+	return c.texts, nil
+}
+
+// ttyMock is a TTY the operator answers the same on.
+type ttyMock struct {
+	answer string
+}
+
+func (t *ttyMock) ReadString() (string, error) {
+	return t.answer, nil
+}
+
+func (t *ttyMock) Close() error {
+	return nil
+}
+
+func TestStepScreenBasedBoot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepScreenBasedBoot)
+}
+
+func TestStepScreenBasedBoot_debugWithoutScreens(t *testing.T) {
+	state := testState(t)
+	state.Put("debug", true)
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	ui.TTY = &ttyMock{answer: "y\n"}
+	step := &StepScreenBasedBoot{VmName: "foo"}
+
+	// Without boot_screen_record, the screens are not recorded, nor is the
+	// operator asked to
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if out := ui.Writer.(*bytes.Buffer).String(); out != "" {
+		t.Fatalf("the operator should not be asked: %s", out)
+	}
+	if state.Get("driver").(*DriverMock).VersionCalled {
+		t.Fatal("the screen should not be captured")
+	}
+}
+
+func TestStepScreenBasedBoot_recordError(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionErr = errors.New("no prlctl")
+	step := &StepScreenBasedBoot{
+		VmName:     "foo",
+		RecordFile: filepath.Join(t.TempDir(), "boot_screen_config.pkr.hcl"),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepScreenBasedBoot_halt(t *testing.T) {
+	state := testState(t)
+	step := &StepScreenBasedBoot{VmName: "foo"}
+
+	screenshot := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := os.WriteFile(screenshot, []byte("png"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ocrWrapper := &ocrWrapperMock{
+		texts: []OCRText{{Text: "An error occurred"}, {Text: "Restart"}},
+	}
+
+	action := step.halt(state, ocrWrapper, screenshot, errors.New("failure screen \"error\" identified"))
+	if action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	m := regexp.MustCompile(`last screenshot: (\S+), its text: (\S+)$`).FindStringSubmatch(err.(error).Error())
+	if m == nil {
+		t.Fatalf("bad error: %s", err)
+	}
+	defer os.Remove(m[1])
+	defer os.Remove(m[2])
+
+	if data, err := os.ReadFile(m[1]); err != nil || string(data) != "png" {
+		t.Fatalf("bad screenshot: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(m[2]); err != nil || string(data) != "An error occurred\nRestart\n" {
+		t.Fatalf("bad text: %q, %v", data, err)
+	}
+}
+
+func TestDetectCaptureWindowId(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+
+	// The screen of the VMs other than macOS is captured by prlctl
+	windowId, err := detectCaptureWindowId(state, "foo", false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if windowId != -1 {
+		t.Fatalf("bad window ID: %d", windowId)
+	}
+	if driver.VersionCalled {
+		t.Fatal("should not check the version")
+	}
+
+	driver.VersionResult = "20.1.0"
+	windowId, err = detectCaptureWindowId(state, "foo", true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if windowId != -1 {
+		t.Fatalf("bad window ID: %d", windowId)
+	}
+}
+
+func TestCapturesWithPrlctl(t *testing.T) {
+	cases := []struct {
+		version string
+		macVM   bool
+		prlctl  bool
+	}{
+		{"19.4.0", false, true},
+		{"19.4.0", true, false},
+		{"20.0.0", true, true},
+		{"20.1.0", true, true},
+	}
+	for _, c := range cases {
+		state := testState(t)
+		state.Get("driver").(*DriverMock).VersionResult = c.version
+		prlctl, err := capturesWithPrlctl(state, c.macVM)
+		if err != nil {
+			t.Fatalf("%s: err: %s", c.version, err)
+		}
+		if prlctl != c.prlctl {
+			t.Errorf("%s, macOS VM %t: bad prlctl capture: %t", c.version, c.macVM, prlctl)
+		}
+	}
+}
@@ -6,6 +6,9 @@ package common
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -18,6 +21,13 @@ type bootCommandTemplateData struct {
 	HTTPIP   string
 	HTTPPort int
 	Name     string
+	// The texts recognized on the screen identified by the screen based boot
+	Texts ScreenTexts
+}
+
+// bootCommandSequence is a sequence of keys, generated from the boot command.
+type bootCommandSequence interface {
+	Do(context.Context, bootcommand.BCDriver) error
 }
 
 // StepTypeBootCommand is a step that "types" the boot command into the VM via
@@ -29,9 +39,20 @@ type StepTypeBootCommand struct {
 	VMName         string
 	Ctx            interpolate.Context
 	GroupInterval  time.Duration
+	KeyInterval    time.Duration
+	KeyboardLayout string
+	// The OCR library recognizing the text of the clickText, waitText and
+	// waitGone directives. Defaults to vision.
+	OCR OCRConfig
+	// The texts recognized on the screen the boot command is typed on, if
+	// any, available as the Texts template data.
+	Texts ScreenTexts
+	// Whether the VM is a macOS VM, whose window is captured by the
+	// directives prior to Parallels Desktop 20.
+	MacVM bool
 }
 
-// Run types the boot command by sending key scancodes into the VM.
+// Run types the boot command by sending key events into the VM.
 func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	debug := state.Get("debug").(bool)
 	httpPort := state.Get("http_port").(int)
@@ -77,12 +98,19 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		hostIP,
 		httpPort,
 		s.VMName,
+		s.Texts,
 	}
 
-	sendCodes := func(codes []string) error {
-		return driver.SendKeyScanCodes(s.VMName, codes...)
+	sendEvents := func(events []KeyEvent) error {
+		return driver.SendKeyEvents(s.VMName, events)
+	}
+	d, err := NewKeycodeDriver(sendEvents, s.KeyboardLayout, s.KeyInterval, s.GroupInterval)
+	if err != nil {
+		err = fmt.Errorf("Error preparing boot command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
-	d := bootcommand.NewPCXTDriver(sendCodes, -1, s.GroupInterval)
 
 	ui.Say("Typing the boot command...")
 	command, err := interpolate.Render(s.BootCommand, &s.Ctx)
@@ -93,7 +121,7 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
-	seq, err := bootcommand.GenerateExpressionSequence(command)
+	parts, err := splitBootCommand(command)
 	if err != nil {
 		err := fmt.Errorf("Error generating boot command: %s", err)
 		state.Put("error", err)
@@ -101,11 +129,44 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
-	if err := seq.Do(ctx, d); err != nil {
-		err := fmt.Errorf("Error running boot command: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	// All the sequences are generated first, and the mouse events checked,
+	// so that nothing is typed if the boot command can't be run.
+	seqs := make([]bootCommandSequence, len(parts))
+	sdkVerified := false
+	for i, part := range parts {
+		if part.directive != nil {
+			if !sdkVerified && part.directive.sendsMouseEvents() {
+				if err := driver.VerifySDK(); err != nil {
+					err := fmt.Errorf("Error preparing boot command: %s requires the Parallels Virtualization SDK: %s", part.directive, err)
+					state.Put("error", err)
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+				sdkVerified = true
+			}
+			continue
+		}
+		seqs[i], err = bootcommand.GenerateExpressionSequence(part.text)
+		if err != nil {
+			err := fmt.Errorf("Error generating boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	for i, part := range parts {
+		if part.directive != nil {
+			err = s.runDirective(ctx, state, part.directive)
+		} else {
+			err = seqs[i].Do(ctx, d)
+		}
+		if err != nil {
+			err := fmt.Errorf("Error running boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	if pauseFn != nil {
@@ -117,3 +178,150 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 
 // Cleanup does nothing.
 func (*StepTypeBootCommand) Cleanup(multistep.StateBag) {}
+
+// waitTextInterval is the interval between the screenshots taken by the
+// waitText and waitGone directives.
+const waitTextInterval = time.Second
+
+// runDirective performs the directive of the boot command.
+func (s *StepTypeBootCommand) runDirective(ctx context.Context, state multistep.StateBag, directive *bootDirective) error {
+	driver := state.Get("driver").(Driver)
+	if directive.name != directiveClick && s.OCR.OCRLibrary == "image" {
+		return fmt.Errorf("%s requires an OCR library recognizing text, not image", directive)
+	}
+
+	switch directive.name {
+	case directiveClick:
+		return driver.SendMouseEvent(s.VMName, directive.x, directive.y, directive.button, MouseActionClick)
+	case directiveClickText:
+		return s.clickText(state, directive.text)
+	case directiveWaitText:
+		return s.waitText(ctx, state, directive.text, false, directive.timeout)
+	case directiveWaitGone:
+		return s.waitText(ctx, state, directive.text, true, directive.timeout)
+	}
+	return fmt.Errorf("unknown directive: %s", directive)
+}
+
+// clickText clicks the center of the text recognized on the screen. It
+// requires 'prlctl capture': a capture of the window of a macOS VM has the
+// title bar and the scale of the host display, so its coordinates aren't
+// those of the guest display.
+func (s *StepTypeBootCommand) clickText(state multistep.StateBag, text string) error {
+	driver := state.Get("driver").(Driver)
+
+	prlctl, err := capturesWithPrlctl(state, s.MacVM)
+	if err != nil {
+		return err
+	}
+	if !prlctl {
+		return fmt.Errorf("clickText requires 'prlctl capture', available for macOS VMs from Parallels Desktop 20")
+	}
+
+	file, err := os.CreateTemp("", "screenshot*.png")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := captureScreen(state, s.VMName, "-1", file.Name()); err != nil {
+		return fmt.Errorf("error capturing the screen: %s", err)
+	}
+
+	ocrWrapper, err := NewOCRWrapper(s.OCR, nil)
+	if err != nil {
+		return err
+	}
+	texts, err := ocrWrapper.RecognizeText(file.Name())
+	if err != nil {
+		return fmt.Errorf("error recognizing the text on the screen: %s", err)
+	}
+
+	found, ok := findText(texts, text)
+	if !ok {
+		return fmt.Errorf("text %q not found on the screen", text)
+	}
+	x, y := found.Center()
+	return driver.SendMouseEvent(s.VMName, x, y, MouseButtonLeft, MouseActionClick)
+}
+
+// waitText blocks until the text appears on the screen or, if gone is true,
+// disappears from it. On timeout, the last screenshot is kept for debugging
+// and its path is part of the error.
+func (s *StepTypeBootCommand) waitText(ctx context.Context, state multistep.StateBag, text string, gone bool, timeout time.Duration) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	if gone {
+		ui.Say(fmt.Sprintf("Waiting %s for %q to disappear from the screen...", timeout, text))
+	} else {
+		ui.Say(fmt.Sprintf("Waiting %s for %q to appear on the screen...", timeout, text))
+	}
+
+	file, err := os.CreateTemp("", "screenshot*.png")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// The screen either shows the text, or is the empty screen
+	const textScreen = "text"
+	ocrWrapper, err := NewOCRWrapper(s.OCR, []BootScreenConfig{
+		{ScreenName: textScreen, MatchingStrings: []string{strings.ToLower(text)}},
+		{ScreenName: "empty"},
+	})
+	if err != nil {
+		return err
+	}
+
+	deadline := time.After(timeout)
+	for {
+		if err := captureScreen(state, s.VMName, "-1", file.Name()); err != nil {
+			return fmt.Errorf("error capturing the screen: %s", err)
+		}
+		screen, _, err := ocrWrapper.IdentifyCurrentScreen(file.Name())
+		if err != nil {
+			return fmt.Errorf("error recognizing the text on the screen: %s", err)
+		}
+		if (screen.ScreenName == textScreen) != gone {
+			return nil
+		}
+
+		select {
+		case <-deadline:
+			verb := "appear on"
+			if gone {
+				verb = "disappear from"
+			}
+			screenshot, err := keepScreenshot(file.Name())
+			if err != nil {
+				return fmt.Errorf("timeout waiting for %q to %s the screen", text, verb)
+			}
+			return fmt.Errorf("timeout waiting for %q to %s the screen, last screenshot: %s", text, verb, screenshot)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitTextInterval):
+		}
+	}
+}
+
+// keepScreenshot copies the screenshot to a file, which isn't removed at the
+// end of the build, and returns its path.
+func keepScreenshot(fileName string) (string, error) {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "packer-screenshot-*.png")
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+	return dst.Name(), nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepTypeBootCommand_impl(t *testing.T) {
+	var _ multistep.Step = new(StepTypeBootCommand)
+}
+
+func TestStepTypeBootCommand_click(t *testing.T) {
+	state := testState(t)
+	state.Put("http_port", 0)
+	step := &StepTypeBootCommand{
+		BootCommand: "a<click 10,20 right>b",
+		VMName:      "foo",
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	if len(driver.SendKeyEventsCalls) != 2 {
+		t.Fatalf("bad: %#v", driver.SendKeyEventsCalls)
+	}
+	if !driver.VerifySDKCalled {
+		t.Fatal("should have checked the SDK")
+	}
+	expected := [][]string{{"foo", "10", "20", MouseButtonRight, MouseActionClick}}
+	if !reflect.DeepEqual(driver.SendMouseEventCalls, expected) {
+		t.Fatalf("bad: %#v", driver.SendMouseEventCalls)
+	}
+}
+
+func TestStepTypeBootCommand_texts(t *testing.T) {
+	state := testState(t)
+	state.Put("http_port", 0)
+	step := &StepTypeBootCommand{
+		BootCommand: `<click {{ (.Texts.Find "Next").CenterX }},{{ (.Texts.Find "Next").CenterY }}>`,
+		VMName:      "foo",
+		Texts: locateTexts([]OCRText{
+			{Text: "Next", X: 800, Y: 700, Width: 100, Height: 30},
+		}, 1024, 768),
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	expected := [][]string{{"foo", "850", "715", MouseButtonLeft, MouseActionClick}}
+	if !reflect.DeepEqual(driver.SendMouseEventCalls, expected) {
+		t.Fatalf("bad: %#v", driver.SendMouseEventCalls)
+	}
+}
+
+func TestStepTypeBootCommand_invalidDirective(t *testing.T) {
+	state := testState(t)
+	state.Put("http_port", 0)
+	step := &StepTypeBootCommand{
+		BootCommand: "a<click 10>",
+		VMName:      "foo",
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if len(driver.SendKeyEventsCalls) != 0 {
+		t.Fatalf("nothing should be typed: %#v", driver.SendKeyEventsCalls)
+	}
+}
+
+func TestStepTypeBootCommand_clickTextWindowCapture(t *testing.T) {
+	state := testState(t)
+	state.Put("http_port", 0)
+	step := &StepTypeBootCommand{
+		BootCommand: `<clickText "Next">`,
+		VMName:      "foo",
+		MacVM:       true,
+	}
+
+	// Before PD 20, the screen of a macOS VM is a capture of its window,
+	// whose coordinates aren't those of the guest display
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "19.4.0"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "clickText requires 'prlctl capture'") {
+		t.Fatalf("bad error: %s", err)
+	}
+	if len(driver.SendMouseEventCalls) != 0 {
+		t.Fatalf("nothing should be clicked: %#v", driver.SendMouseEventCalls)
+	}
+}
+
+func TestStepTypeBootCommand_clickWithoutSDK(t *testing.T) {
+	state := testState(t)
+	state.Put("http_port", 0)
+	step := &StepTypeBootCommand{
+		BootCommand: "a<click 10,20>b<click 30,40>",
+		VMName:      "foo",
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VerifySDKErr = errors.New("No module named 'prlsdkapi'")
+
+	// Nothing is typed before the missing SDK is reported
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "<click 10,20 left> requires the Parallels Virtualization SDK") {
+		t.Fatalf("bad error: %s", err)
+	}
+	if len(driver.SendKeyEventsCalls) != 0 || len(driver.SendMouseEventCalls) != 0 {
+		t.Fatalf("nothing should be sent: %#v %#v", driver.SendKeyEventsCalls, driver.SendMouseEventCalls)
+	}
+}
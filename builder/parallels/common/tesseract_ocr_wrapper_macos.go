@@ -10,19 +10,23 @@ import (
 	"errors"
 	"log"
 	"os/exec"
-	"strings"
 )
 
 type TesseractOCRWrapper struct {
-	ScreenConfigs map[string]BootScreenConfig
+	matcher *screenMatcher
 }
 
-func NewTesseractOCRWrapper(ScreenConfigs map[string]BootScreenConfig) *TesseractOCRWrapper {
+func NewTesseractOCRWrapper(ScreenConfigs []BootScreenConfig) (*TesseractOCRWrapper, error) {
+	matcher, err := newScreenMatcher(ScreenConfigs)
+	if err != nil {
+		return nil, err
+	}
+
 	tesseractOCRWrapper := TesseractOCRWrapper{
-		ScreenConfigs: ScreenConfigs,
+		matcher: matcher,
 	}
 
-	return &tesseractOCRWrapper
+	return &tesseractOCRWrapper, nil
 }
 
 func executeBinary(binaryPath string, args ...string) (string, error) {
@@ -40,60 +44,41 @@ func executeBinary(binaryPath string, args ...string) (string, error) {
 	return result, nil
 }
 
-func (s *TesseractOCRWrapper) detectScreen(text string) BootScreenConfig {
-	text = strings.ToLower(text)
-	emptyBootConfig := BootScreenConfig{}
-
-	for _, screenConfig := range s.ScreenConfigs {
-		// Empty screens are not considered for matching
-		if (screenConfig.MatchingStrings == nil) || (len(screenConfig.MatchingStrings) == 0) {
-			emptyBootConfig = screenConfig
-			continue
-		}
-
-		// Check if all screenConfig.MatchingStrings are present in text. If all are present, return the screenConfig
-		matching := true
-		for _, matchingString := range screenConfig.MatchingStrings {
-			if !strings.Contains(text, matchingString) {
-				matching = false
-				break
-			}
-		}
-
-		if matching {
-			return screenConfig
-		}
+func (c *TesseractOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	texts, err := c.RecognizeText(imagePath)
+	if err != nil {
+		return BootScreenConfig{}, nil, err
 	}
 
-	return emptyBootConfig
-}
-
-func (c *TesseractOCRWrapper) detectTextFromImageUsingTesseract(imagePath string) (string, error) {
-	tesseractPath, err := exec.LookPath("tesseract")
+	screenTexts, err := locateImageTexts(texts, imagePath)
 	if err != nil {
-		return "", errors.New("tesseract binary not found in PATH. Try installing tesseract")
+		return BootScreenConfig{}, nil, err
 	}
 
-	// Execute binary 'tesseract imagePath stdout' to extract text from image
-	detectedText, execErr := executeBinary(tesseractPath, imagePath, "stdout")
-	if execErr != nil {
-		return "", execErr
+	log.Println("Detected text: ", screenTexts)
+	screenConfig, _ := c.matcher.matchWords(screenTexts)
+	if screenConfig.IsEmpty() {
+		c.matcher.logNearMisses(screenTexts)
 	}
-
-	detectedText = strings.ReplaceAll(detectedText, "\n", " ")
-	return detectedText, nil
+	return screenConfig, screenTexts, nil
 }
 
-func (c *TesseractOCRWrapper) IdentifyCurrentScreen(imagePath string) (bootScreenConfig BootScreenConfig, err error) {
-	text, err := c.detectTextFromImageUsingTesseract(imagePath)
+// RecognizeText returns the lines of text recognized in the image, with their
+// bounding boxes.
+func (c *TesseractOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	tesseractPath, err := exec.LookPath("tesseract")
 	if err != nil {
-		return BootScreenConfig{}, err
+		return nil, errors.New("tesseract binary not found in PATH. Try installing tesseract")
 	}
 
-	log.Println("Detected text: ", text)
-	return c.detectScreen(text), nil
+	// Execute binary 'tesseract imagePath stdout tsv' to extract the words and their positions
+	tsv, err := executeBinary(tesseractPath, imagePath, "stdout", "tsv")
+	if err != nil {
+		return nil, err
+	}
+	return parseTesseractTSV(tsv)
 }
 
 func (c *TesseractOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
-	delete(c.ScreenConfigs, screenName)
+	c.matcher.remove(screenName)
 }
@@ -14,12 +14,16 @@ import "errors"
 
 type TesseractOCRWrapper struct{}
 
-func NewTesseractOCRWrapper(ScreenConfigs map[string]BootScreenConfig) *TesseractOCRWrapper {
-	return &TesseractOCRWrapper{}
+func NewTesseractOCRWrapper(ScreenConfigs []BootScreenConfig) (*TesseractOCRWrapper, error) {
+	return &TesseractOCRWrapper{}, nil
 }
 
-func (c *TesseractOCRWrapper) IdentifyCurrentScreen(imagePath string) (bootScreenConfig BootScreenConfig, err error) {
-	return BootScreenConfig{}, errors.New("TesseractOCRWrapper is not implemented other than darwin")
+func (c *TesseractOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	return BootScreenConfig{}, nil, errors.New("TesseractOCRWrapper is not implemented other than darwin")
+}
+
+func (c *TesseractOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	return nil, errors.New("TesseractOCRWrapper is not implemented other than darwin")
 }
 
 func (c *TesseractOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
@@ -3,114 +3,120 @@
 #import <Vision/Vision.h>
 #import <AppKit/AppKit.h>
 
-@interface BootScreenConfig : NSObject
-@property(readonly) NSMutableArray<NSString*>* matchingStrings;
-@property(readonly) NSString* screenName;
+@interface OCRImpl : NSObject
 @end
 
-@implementation BootScreenConfig
-- (id) init
-{
-	if (self = [super init]) {
-		_matchingStrings = [[NSMutableArray alloc] init];
-	}
-	return self;
-}
+@implementation OCRImpl
 
-- (void) setScreenName:(const char*)screenName
+-(NSArray<VNRecognizedTextObservation *>*) recognizeText:(CIImage*)image errorBuffer:(char **)errorBuffer
 {
-	_screenName = [NSString stringWithUTF8String:screenName];
-}
+	//Recognizing text
+	dispatch_semaphore_t sem = dispatch_semaphore_create(0);
+	__block NSArray<VNRecognizedTextObservation *> *textObservations;
+	VNRecognizeTextRequest *request = [[VNRecognizeTextRequest alloc] initWithCompletionHandler:^(VNRequest * _Nonnull request, NSError * _Nullable error) {
+		if (error)
+		{
+			*errorBuffer = strdup(error.localizedDescription.UTF8String);
+			dispatch_semaphore_signal(sem);
+			return;
+		}
 
-- (void) addMatchingString:(const char*)matchingString
-{
-	[_matchingStrings addObject:[NSString stringWithUTF8String:matchingString]];
-}
-@end
+		textObservations = request.results;
+		dispatch_semaphore_signal(sem);
+	}];
+	request.recognitionLevel = VNRequestTextRecognitionLevelAccurate;
+	//request.revision = VNRecognizeTextRequestRevision3;
 
-@interface OCRImpl : NSObject
-@end
+	NSError *error;
+	VNImageRequestHandler *docRequestHandler = [[VNImageRequestHandler alloc] initWithCIImage:image options:@{}];
+	[docRequestHandler performRequests:@[request] error:&error];
 
-@implementation OCRImpl
-{
-	//Array of matching strings of screen configurations - for verification purposes
-	NSMutableArray<BootScreenConfig*>* screenConfigs;
-}
-- (id) init
-{
-	if (self = [super init]) {
-		screenConfigs = [[NSMutableArray alloc] init];
-	}
-	return self;
+	//Wait for processing to complete
+	dispatch_semaphore_wait(sem, DISPATCH_TIME_FOREVER);
+
+	return textObservations;
 }
 
-- (void) addBootScreenConfig:(BootScreenConfig*)config
+- (CIFilter*) scaleFilter:(CIImage*)image factor:(NSNumber*)factor
 {
-	[screenConfigs addObject:config];
+	CIFilter *scaleFilter = [CIFilter filterWithName:@"CILanczosScaleTransform"];
+	[scaleFilter setValue:image forKey:kCIInputImageKey];
+	[scaleFilter setValue:factor forKey:kCIInputScaleKey];
+	[scaleFilter setValue:@1.0 forKey:kCIInputAspectRatioKey]; // Maintain aspect ratio
+
+	return scaleFilter;
 }
 
-- (void) deleteBootScreenConfig:(char *)screenName
+
+/**
+ imagePath - image to run OCR
+ scaleFactor - the image is scaled by this factor before the OCR, which improves its accuracy, 1.0 for none
+ resultBuffer - returns with the recognized lines, their confidence and their bounding boxes in pixels of
+	the image, not scaled, as a JSON array
+ errorBuffer - returns with error text if any
+ */
+- (void) recognizeTextFrom:(const char *)imagePath ScaleFactor:(double)scaleFactor Result:(char**)resultBuffer
+				ErrorBuffer:(char **)errorBuffer
 {
-	BootScreenConfig* config = nil;
-	for (BootScreenConfig* bsc in screenConfigs)
-	{
-		if ([bsc.screenName isEqualToString:[NSString stringWithUTF8String:screenName]])
+	@autoreleasepool {
+		//Creating the image
+		NSString* imagePathStr = [[NSString alloc] initWithCString:imagePath encoding:NSUTF8StringEncoding];
+		NSURL* url = [[NSURL alloc] initFileURLWithPath:imagePathStr];
+		CIImage* image = [[CIImage alloc] initWithContentsOfURL:url];
+		if (!image)
 		{
-			config = bsc;
-			break;
+			*errorBuffer = strdup("an image does not exist at the path");
+			return;
 		}
-	}
 
-	if (config)
-		[screenConfigs removeObject:config];
-}
+		CGRect extent = [image extent];
+		CGFloat width = CGRectGetWidth(extent);
+		CGFloat height = CGRectGetHeight(extent);
 
-- (NSMutableArray<NSMutableArray<NSNumber *>*>*) createCheckBoxesForScreenConfigs
-{
-	NSMutableArray* ret = [[NSMutableArray alloc] init];
-	for (NSArray* config in screenConfigs)
-	{
-		NSMutableArray* checks = [[NSMutableArray alloc] init];
-		BootScreenConfig *bsc = (BootScreenConfig*)config;
-		for (int i = 0; i < bsc.matchingStrings.count; i++)
+		// Get the scaled image
+		CIImage* scaledImage = image;
+		if (scaleFactor != 1.0)
 		{
-			[checks addObject:[[NSNumber alloc] initWithBool:NO]];
+			NSNumber* factor = [[NSNumber alloc] initWithDouble:scaleFactor];
+			CIFilter* filter = [self scaleFilter:image factor:factor];
+			scaledImage = [filter outputImage];
 		}
 
-		[ret addObject:checks];
-	}
-
-	return ret;
-}
+		// Recognize the text
+		NSArray<VNRecognizedTextObservation *> * textObservations = [self recognizeText:scaledImage errorBuffer:errorBuffer];
+		if (!textObservations)
+			return;
 
-- (int) checkForMatches:(NSString*)recognizedText checkBox:(NSMutableArray<NSMutableArray<NSNumber *>*>*)checkBox
-{
-	int emptyBootScreenIndex = -1;
-	for (int i = 0; i < screenConfigs.count; i++)
-	{
-		if (screenConfigs[i].matchingStrings.count == 0)
+		NSMutableArray* lines = [[NSMutableArray alloc] init];
+		for (VNRecognizedTextObservation* observation in textObservations)
 		{
-			emptyBootScreenIndex = i;
-			continue;
+			NSArray<VNRecognizedText*>* text = [observation topCandidates:1];
+			if (!text || text.count == 0 || text[0].string.length == 0)
+				continue;
+
+			// The bounding box is normalized, with the origin at the bottom left
+			// corner, so it is the same in the image and in the scaled image
+			CGRect box = observation.boundingBox;
+			[lines addObject:@{
+				@"text": text[0].string,
+				@"x": @((int)(box.origin.x * width)),
+				@"y": @((int)((1.0 - box.origin.y - box.size.height) * height)),
+				@"width": @((int)(box.size.width * width)),
+				@"height": @((int)(box.size.height * height)),
+				@"confidence": @(observation.confidence),
+			}];
 		}
 
-		bool match = true;
-		for (int j = 0; j < screenConfigs[i].matchingStrings.count; j++)
-			if (![checkBox[i][j] boolValue])
-			{
-				if ([recognizedText containsString:screenConfigs[i].matchingStrings[j]])
-					checkBox[i][j] = @YES;
-				else
-					match = false;
-			}
-
-		if (match)
-			return i;
+		NSData* json = [NSJSONSerialization dataWithJSONObject:lines options:0 error:nil];
+		NSString* jsonStr = [[NSString alloc] initWithData:json encoding:NSUTF8StringEncoding];
+		*resultBuffer = strdup(jsonStr.UTF8String);
 	}
-
-	return emptyBootScreenIndex;
 }
 
+@end
+
+@implementation OCRImpl
+
 -(NSArray<VNRecognizedTextObservation *>*) recognizeText:(CIImage*)image errorBuffer:(char **)errorBuffer
 {
 	//Recognizing text
@@ -153,19 +159,17 @@
 
 /**
  imagePath - image to run OCR
- refScaleFactor [should be 0.0 or above] - Represents the image scale factor to improve OCR accuracy.
- if 0.0, OCR accuracy will be checked by resizing image based on width from 1000-2000px, best result will be returned.
- if >0.0, OCR accuracy will be checked from refScaleFactor ± 10% and best result will be returned
+ scaleFactor - the image is scaled by this factor before the OCR, which improves its accuracy
+ textBuffer - returns with the recognized text, the lines separated by spaces
+ confidence - returns with the overall confidence of the recognized text
  errorBuffer - returns with error text if any
- topConfidenceScaleFactor - returns the scale factor resulted in best OCR accuracy. You can use this as refScaleFactor for next calls with similar images
- detectedScreenName - empty/null if none of the screens are matching/error. The screen name, if matched a screen
  */
-- (void) detectScreenFrom:(const char *)imagePath ScaleFactor:(double)refScaleFactor BestRecognizedText:(char**)textBuffer 
-					ErrorBuffer:(char **)errorBuffer TopScaleFactor:(double*)topConfidenceScaleFactor Result:(char**)detectedScreenName
+- (void) recognizeTextFrom:(const char *)imagePath ScaleFactor:(double)scaleFactor Text:(char**)textBuffer
+				Confidence:(double*)confidence ErrorBuffer:(char **)errorBuffer
 {
 	@autoreleasepool {
 		//Creating the image
-		NSString* imagePathStr = [[NSString alloc] initWithCString:imagePath encoding:kUnicodeUTF8Format];
+		NSString* imagePathStr = [[NSString alloc] initWithCString:imagePath encoding:NSUTF8StringEncoding];
 		NSURL* url = [[NSURL alloc] initFileURLWithPath:imagePathStr];
 		CIImage* image = [[CIImage alloc] initWithContentsOfURL:url];
 		if (!image)
@@ -174,73 +178,82 @@
 			return;
 		}
 
-		// Get the extent of the image
-		CGRect extent = [image extent];
-		// Extract the width and height
-		CGFloat width = CGRectGetWidth(extent);
-		// Need to iterate width between 1000-2000px to get accurate OCR
-		CGFloat begin = 1000 / width;
-		CGFloat end = 2000 / width;
-		if (refScaleFactor > 0.0)
-		{
-			begin = refScaleFactor - 0.1;
-			end = refScaleFactor + 0.1;
-		}
-
-		int result = -1;
-		CGFloat topConfidence = 0.0;
-		CGFloat topConfidenceScalingFactor = 0.0;
-		NSString *bestRecognizedText = NSString.string;
-		NSMutableArray<NSMutableArray<NSNumber *>*>* checkBox = [self createCheckBoxesForScreenConfigs];
+		// Get the scaled image
+		NSNumber* factor = [[NSNumber alloc] initWithDouble:scaleFactor];
+		CIFilter* filter = [self scaleFilter:image factor:factor];
+		CIImage* scaledImage = [filter outputImage];
+		// Recognize the text
+		NSArray<VNRecognizedTextObservation *> * textObservations = [self recognizeText:scaledImage errorBuffer:errorBuffer];
+		if (!textObservations)
+			return;
 
-		for (; begin < end; begin += 0.01)
+		// Calculating the overall confidence
+		CGFloat currentConfidence = 0.0;
+		NSString* recognizedText = NSString.string;
+		for (VNRecognizedTextObservation* observation in textObservations)
 		{
-			NSNumber* scaleFactor = [[NSNumber alloc] initWithFloat:begin];
-			// Get the scaled image
-			CIFilter* filter = [self scaleFilter:image factor:scaleFactor];
-			CIImage* scaledImage = [filter outputImage];
-			// Recognize the text
-			NSArray<VNRecognizedTextObservation *> * textObservations = [self recognizeText:scaledImage errorBuffer:errorBuffer];
-			if (!textObservations)
-				return;
-
-			// Calculating the overall confidence
-			CGFloat currentConfidence = 0.0;
-			NSString* recognizedText = NSString.string;
-			for (VNRecognizedTextObservation* observation in textObservations)
+			NSArray<VNRecognizedText*>* text = [observation topCandidates:1];
+			if (text && text[0] && text[0].string.length > 0)
 			{
-				NSArray<VNRecognizedText*>* text = [observation topCandidates:1];
-				if (text && text[0] && text[0].string.length > 0)
-				{
-					recognizedText = [recognizedText stringByAppendingString:text[0].string];
-					recognizedText = [recognizedText stringByAppendingString:@" "];
-					currentConfidence += observation.confidence;
-				}
+				recognizedText = [recognizedText stringByAppendingString:text[0].string];
+				recognizedText = [recognizedText stringByAppendingString:@" "];
+				currentConfidence += observation.confidence;
 			}
+		}
 
-			// Check if the text is matching any screen specified by the user
-			result = [self checkForMatches:[recognizedText lowercaseString] checkBox:checkBox];
-			if (result != -1 && screenConfigs[result].matchingStrings.count != 0)
-			{
-				topConfidenceScalingFactor = begin;
-				bestRecognizedText = @"";
-				break;
-			}
+		*confidence = currentConfidence;
+		*textBuffer = strdup(recognizedText.UTF8String);
+	}
+}
 
-			if (currentConfidence > topConfidence)
-			{
-				topConfidence = currentConfidence;
-				topConfidenceScalingFactor = begin;
-				bestRecognizedText = recognizedText;
-			}
+/**
+ imagePath - image to run OCR
+ resultBuffer - returns with the recognized lines and their bounding boxes in pixels, as a JSON array
+ errorBuffer - returns with error text if any
+ */
+- (void) recognizeTextFrom:(const char *)imagePath Result:(char**)resultBuffer ErrorBuffer:(char **)errorBuffer
+{
+	@autoreleasepool {
+		//Creating the image
+		NSString* imagePathStr = [[NSString alloc] initWithCString:imagePath encoding:NSUTF8StringEncoding];
+		NSURL* url = [[NSURL alloc] initFileURLWithPath:imagePathStr];
+		CIImage* image = [[CIImage alloc] initWithContentsOfURL:url];
+		if (!image)
+		{
+			*errorBuffer = strdup("an image does not exist at the path");
+			return;
 		}
 
-		if (topConfidenceScaleFactor)
-			*topConfidenceScaleFactor = topConfidenceScalingFactor;
-		*textBuffer = strdup(bestRecognizedText.UTF8String);
+		CGRect extent = [image extent];
+		CGFloat width = CGRectGetWidth(extent);
+		CGFloat height = CGRectGetHeight(extent);
+
+		NSArray<VNRecognizedTextObservation *> * textObservations = [self recognizeText:image errorBuffer:errorBuffer];
+		if (!textObservations)
+			return;
+
+		NSMutableArray* lines = [[NSMutableArray alloc] init];
+		for (VNRecognizedTextObservation* observation in textObservations)
+		{
+			NSArray<VNRecognizedText*>* text = [observation topCandidates:1];
+			if (!text || text.count == 0 || text[0].string.length == 0)
+				continue;
+
+			// The bounding box is normalized, with the origin at the bottom left corner
+			CGRect box = observation.boundingBox;
+			[lines addObject:@{
+				@"text": text[0].string,
+				@"x": @((int)(box.origin.x * width)),
+				@"y": @((int)((1.0 - box.origin.y - box.size.height) * height)),
+				@"width": @((int)(box.size.width * width)),
+				@"height": @((int)(box.size.height * height)),
+				@"confidence": @(observation.confidence),
+			}];
+		}
 
-		if (result != -1)
-			*detectedScreenName = strdup(screenConfigs[result].screenName.UTF8String);
+		NSData* json = [NSJSONSerialization dataWithJSONObject:lines options:0 error:nil];
+		NSString* jsonStr = [[NSString alloc] initWithData:json encoding:NSUTF8StringEncoding];
+		*resultBuffer = strdup(jsonStr.UTF8String);
 	}
 }
 
@@ -257,37 +270,10 @@ static OCRImpl* newOCRImpl()
 	return impl;
 }
 
-static BootScreenConfig* newBootScreenConfig()
-{
-	BootScreenConfig* bsc = [[BootScreenConfig alloc] init];
-	return bsc;
-}
-
-static void detectScreenFromImage(OCRImpl* impl, const char* imagePath, double refScaleFactor, 
-			char** bestRecognizedText, char** errorBuffer, double* topConfidenceScaleFactor, char** detectedScreenName)
-{
-	return [impl detectScreenFrom:imagePath ScaleFactor:refScaleFactor BestRecognizedText:bestRecognizedText 
-						ErrorBuffer:errorBuffer TopScaleFactor:topConfidenceScaleFactor Result:detectedScreenName];
-}
-
-static void addMatchingString(BootScreenConfig *config, const char* mStr)
-{
-	[config addMatchingString:mStr];
-}
-
-static void setScreenName(BootScreenConfig *config, const char* screenName)
-{
-	[config setScreenName:screenName];
-}
-
-static void addBootScreenConfig(OCRImpl* impl, BootScreenConfig* config)
-{
-	[impl addBootScreenConfig:config];
-}
-
-static void deleteBootScreenConfig(OCRImpl* impl, char* screenName)
+static void recognizeTextFromImage(OCRImpl* impl, const char* imagePath, double scaleFactor,
+			char** resultBuffer, char** errorBuffer)
 {
-	[impl deleteBootScreenConfig:screenName];
+	return [impl recognizeTextFrom:imagePath ScaleFactor:scaleFactor Result:resultBuffer ErrorBuffer:errorBuffer];
 }
 
 #ifdef __cplusplus
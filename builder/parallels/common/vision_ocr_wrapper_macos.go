@@ -17,129 +17,196 @@ package common
 import "C"
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
 	"log"
-	"strings"
+	"os"
 	"unsafe"
 )
 
 // VisionOCRWrapper is a struct that acts as an Adapter to Apple's Vision Framework for Optical Character Recognition.
 type VisionOCRWrapper struct {
-	referenceScalingFactor float32
-	ScreenConfigs          map[string]BootScreenConfig
+	referenceScalingFactor float64
+	matcher                *screenMatcher
 	OCRImpl                *C.OCRImpl
 }
 
-func NewVisionOCRWrapper(ScreenConfigs map[string]BootScreenConfig) *VisionOCRWrapper {
+func NewVisionOCRWrapper(ScreenConfigs []BootScreenConfig) (*VisionOCRWrapper, error) {
+	matcher, err := newScreenMatcher(ScreenConfigs)
+	if err != nil {
+		return nil, err
+	}
+
 	ocrRunner := VisionOCRWrapper{
 		referenceScalingFactor: 0.0,
-		ScreenConfigs:          ScreenConfigs,
+		matcher:                matcher,
 		OCRImpl:                C.newOCRImpl(),
 	}
 
-	for _, screenConfig := range ScreenConfigs {
-		bootScreenConfig := C.newBootScreenConfig()
-
-		// Add the screen name
-		C.setScreenName(bootScreenConfig, C.CString(screenConfig.ScreenName))
-		for _, matchingString := range screenConfig.MatchingStrings {
-			lowerCase := strings.ToLower(matchingString)
-			C.addMatchingString(bootScreenConfig, C.CString(lowerCase))
-		}
+	return &ocrRunner, nil
+}
 
-		C.addBootScreenConfig(ocrRunner.OCRImpl, bootScreenConfig)
+// recognizeScaledText returns the lines of text recognized in the image,
+// scaled by the factor, with their bounding boxes in the image, not scaled.
+func (c *VisionOCRWrapper) recognizeScaledText(imagePath string, scalingFactor float64) ([]OCRText, error) {
+	cImagePath := C.CString(imagePath)
+	defer C.free(unsafe.Pointer(cImagePath))
+
+	var resultBuffer, errorBuffer *C.char
+	C.recognizeTextFromImage(c.OCRImpl, cImagePath, C.double(scalingFactor), &resultBuffer, &errorBuffer)
+	if errorBuffer != nil {
+		defer C.free(unsafe.Pointer(errorBuffer))
+		return nil, errors.New(C.GoString(errorBuffer))
+	}
+	if resultBuffer == nil {
+		return nil, nil
 	}
+	defer C.free(unsafe.Pointer(resultBuffer))
 
-	return &ocrRunner
+	var texts []OCRText
+	if err := json.Unmarshal([]byte(C.GoString(resultBuffer)), &texts); err != nil {
+		return nil, err
+	}
+	return texts, nil
 }
 
-func (c *VisionOCRWrapper) detectScreenFromImageUsingVisionAPI(imagePath string, useRefScalingFactor bool, screenName chan<- string, err chan<- error) {
-	errorBuffer := C.CString("")
-	defer C.free(unsafe.Pointer(errorBuffer))
-	textBuffer := C.CString("")
-	defer C.free(unsafe.Pointer(textBuffer))
-	detectedScreenName := C.CString("")
-	defer C.free(unsafe.Pointer(detectedScreenName))
-
-	refScalingFactor := C.double(0.0)
-	if useRefScalingFactor {
-		refScalingFactor = C.double(c.referenceScalingFactor)
+// detectScreen recognizes the text of the image at several scaling factors,
+// until a screen other than the empty screen matches the text recognized so
+// far. The OCR accuracy depends on the scaling factor.
+//
+// If refScalingFactor is 0.0, the image is resized to a width from 1000 to
+// 2000px. Otherwise, the scaling factor ranges from refScalingFactor ± 10%.
+// The scaling factor of the match, or of the best confidence, is kept as the
+// reference for the next screens, as they are most likely similar.
+func (c *VisionOCRWrapper) detectScreen(imagePath string, refScalingFactor float64) (BootScreenConfig, bool, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return BootScreenConfig{}, false, errors.New("an image does not exist at the path")
+	}
+	imageConfig, _, err := image.DecodeConfig(file)
+	file.Close()
+	if err != nil {
+		return BootScreenConfig{}, false, fmt.Errorf("error decoding the image: %s", err)
 	}
 
-	bestScalingFactor := C.double(0.0)
+	begin := 1000 / float64(imageConfig.Width)
+	end := 2000 / float64(imageConfig.Width)
+	if refScalingFactor > 0.0 {
+		begin = refScalingFactor - 0.1
+		end = refScalingFactor + 0.1
+	}
 
-	C.detectScreenFromImage(c.OCRImpl, C.CString(imagePath), refScalingFactor, &textBuffer, &errorBuffer, &bestScalingFactor, &detectedScreenName)
-	result := C.GoString(detectedScreenName)
-	errorString := C.GoString(errorBuffer)
+	// The lines recognized at any of the scaling factors count, with their
+	// location and confidence for the screens with a region or a
+	// min_confidence
+	var recognizedTexts []OCRText
+	topConfidence := 0.0
+	topConfidenceScalingFactor := 0.0
+	bestRecognizedText := ""
+	for scalingFactor := begin; scalingFactor < end; scalingFactor += 0.01 {
+		texts, err := c.recognizeScaledText(imagePath, scalingFactor)
+		if err != nil {
+			return BootScreenConfig{}, false, err
+		}
 
-	if len(errorString) != 0 {
-		screenName <- result
-		err <- errors.New(errorString)
-		return
+		recognizedTexts = append(recognizedTexts, texts...)
+		screenTexts := locateTexts(recognizedTexts, imageConfig.Width, imageConfig.Height)
+		screenConfig, ok := c.matcher.matchWords(screenTexts)
+		if ok && !screenConfig.IsEmpty() {
+			c.referenceScalingFactor = scalingFactor
+			return screenConfig, true, nil
+		}
+
+		// The overall confidence of the recognition
+		confidence := 0.0
+		for _, text := range texts {
+			confidence += text.Confidence
+		}
+		if confidence > topConfidence {
+			topConfidence = confidence
+			topConfidenceScalingFactor = scalingFactor
+			bestRecognizedText = locateTexts(texts, imageConfig.Width, imageConfig.Height).String()
+		}
 	}
-	c.referenceScalingFactor = float32(bestScalingFactor)
+	c.referenceScalingFactor = topConfidenceScalingFactor
 
-	// Printing the textBuffer helps users to fine tune their screen configurations
-	if C.GoString(textBuffer) != "" {
-		log.Printf("Best detected text: %s", C.GoString(textBuffer))
+	// Printing the best text helps users to fine tune their screen configurations
+	if bestRecognizedText != "" {
+		log.Printf("Best detected text: %s", bestRecognizedText)
 	}
 
-	screenName <- result
-	err <- nil
+	screenTexts := locateTexts(recognizedTexts, imageConfig.Width, imageConfig.Height)
+	screenConfig, ok := c.matcher.matchWords(screenTexts)
+	if screenConfig.IsEmpty() {
+		c.matcher.logNearMisses(screenTexts)
+	}
+	return screenConfig, ok, nil
 }
 
 // Extracts text from the image at the given path. Uses "Accurate" recognition level.
-func (c *VisionOCRWrapper) IdentifyCurrentScreen(imagePath string) (bootScreenConfig BootScreenConfig, err error) {
+func (c *VisionOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	// The lines of text with their location identify the screens with a
+	// region, and are returned for the boot command
+	texts, err := c.RecognizeText(imagePath)
+	if err != nil {
+		log.Printf("Screen detection error : %s", err)
+		return BootScreenConfig{}, nil, err
+	}
+	screenTexts, err := locateImageTexts(texts, imagePath)
+	if err != nil {
+		log.Printf("Screen detection error : %s", err)
+		return BootScreenConfig{}, nil, err
+	}
+	if screenConfig, ok := c.matcher.matchWords(screenTexts); ok && !screenConfig.IsEmpty() {
+		log.Printf("Final detected screen name : %s ", screenConfig.ScreenName)
+		return screenConfig, screenTexts, nil
+	}
+
 	// A reference scaling factor will be tried first.
 	// If it fails, the scaling factor will be set to 0.0 and the OCR will be tried again.
-	useRefScalingFactor := true
+	useRefScalingFactor := c.referenceScalingFactor > 0.0
 	screenConfig := BootScreenConfig{}
 
 	for {
-		// Use Vision Framework for OCR
-		chanScreenName := make(chan string)
-		chanErr := make(chan error)
-		go c.detectScreenFromImageUsingVisionAPI(imagePath, useRefScalingFactor, chanScreenName, chanErr)
-		screenName := <-chanScreenName
-		err := <-chanErr
-
-		log.Printf("Detected screen name : '%s'", screenName)
-		if err != nil {
-			log.Printf("Screen detection error : %s", err)
-			return BootScreenConfig{}, err
+		refScalingFactor := 0.0
+		if useRefScalingFactor {
+			refScalingFactor = c.referenceScalingFactor
 		}
 
-		if len(screenName) == 0 {
-			if useRefScalingFactor { // Didn't match any screen, try again without scaling factor
-				useRefScalingFactor = false
-				continue
-			} else {
-				return BootScreenConfig{}, errors.New("unable to detect screen")
-			}
-		}
-
-		exists := false
-		screenConfig, exists = c.ScreenConfigs[screenName]
-		if !exists {
-			return BootScreenConfig{}, errors.New("detected screen not found in the configuration, please report this bug")
+		var matched bool
+		screenConfig, matched, err = c.detectScreen(imagePath, refScalingFactor)
+		log.Printf("Detected screen name : '%s'", screenConfig.ScreenName)
+		if err != nil {
+			log.Printf("Screen detection error : %s", err)
+			return BootScreenConfig{}, nil, err
 		}
 
-		// Did we detect an empty screen ? Try again without scaling factor to be sure
-		if useRefScalingFactor && len(screenConfig.MatchingStrings) == 0 {
+		// Didn't match any screen, or matched the empty screen ? Try again
+		// without scaling factor to be sure
+		if useRefScalingFactor && (!matched || screenConfig.IsEmpty()) {
 			useRefScalingFactor = false
-		} else {
-			if len(screenName) == 0 {
-				return BootScreenConfig{}, errors.New("unable to detect screen")
-			}
+			continue
+		}
 
-			break
+		if !matched {
+			return BootScreenConfig{}, nil, errors.New("unable to detect screen")
 		}
+		break
 	}
 
 	log.Printf("Final detected screen name : %s ", screenConfig.ScreenName)
-	return screenConfig, nil
+	return screenConfig, screenTexts, nil
+}
+
+// RecognizeText returns the lines of text recognized in the image, with their
+// bounding boxes.
+func (c *VisionOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	return c.recognizeScaledText(imagePath, 1.0)
 }
 
 func (c *VisionOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
-	C.deleteBootScreenConfig(c.OCRImpl, C.CString(screenName))
+	c.matcher.remove(screenName)
 }
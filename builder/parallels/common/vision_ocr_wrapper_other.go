@@ -13,14 +13,18 @@ import "errors"
 // but it will not perform any actual OCR operations.
 type VisionOCRWrapper struct{}
 
-func (c *VisionOCRWrapper) IdentifyCurrentScreen(imagePath string) (bootScreenConfig BootScreenConfig, err error) {
-	return BootScreenConfig{}, errors.New("VisionOCRWrapper is not implemented other than darwin")
+func (c *VisionOCRWrapper) IdentifyCurrentScreen(imagePath string) (BootScreenConfig, ScreenTexts, error) {
+	return BootScreenConfig{}, nil, errors.New("VisionOCRWrapper is not implemented other than darwin")
+}
+
+func (c *VisionOCRWrapper) RecognizeText(imagePath string) ([]OCRText, error) {
+	return nil, errors.New("VisionOCRWrapper is not implemented other than darwin")
 }
 
 func (c *VisionOCRWrapper) RemoveBootScreenConfigIfExist(screenName string) {
 	// No operation
 }
 
-func NewVisionOCRWrapper(ScreenConfigs map[string]BootScreenConfig) *VisionOCRWrapper {
-	return &VisionOCRWrapper{}
+func NewVisionOCRWrapper(ScreenConfigs []BootScreenConfig) (*VisionOCRWrapper, error) {
+	return &VisionOCRWrapper{}, nil
 }
@@ -10,7 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"time"
 
 	parallelscommon "github.com/Parallels/packer-plugin-parallels/builder/parallels/common"
 	"github.com/hashicorp/hcl/v2/hcldec"
@@ -33,34 +33,26 @@ type Builder struct {
 }
 
 type Config struct {
-	common.PackerConfig                 `mapstructure:",squash"`
-	commonsteps.HTTPConfig              `mapstructure:",squash"`
-	bootcommand.BootConfig              `mapstructure:",squash"`
-	parallelscommon.OutputConfig        `mapstructure:",squash"`
-	parallelscommon.HWConfig            `mapstructure:",squash"`
-	parallelscommon.PrlctlConfig        `mapstructure:",squash"`
-	parallelscommon.PrlctlPostConfig    `mapstructure:",squash"`
-	parallelscommon.PrlctlVersionConfig `mapstructure:",squash"`
-	shutdowncommand.ShutdownConfig      `mapstructure:",squash"`
-	parallelscommon.SSHConfig           `mapstructure:",squash"`
-	parallelscommon.VMConfig            `mapstructure:",squash"`
-
-	// Screens and it's boot configs
-	// A screen is considered matched if all the matching strings are present in the screen.
-	// The first matching screen will be considered & boot config of that screen will be used.
-	// If matching strings are empty, then it is considered as empty screen,
-	// empty screen has some special meaning, which will be considered when none of the other screens are matched.
-	// You can use this screen to make system wait for some time / execute a common boot command etc.
-	// The empty screen boot command will be executed repeatedly until a non-empty screen is found.
-	// If more than one empty screen is found, then it is considered as an error.
-	BootScreenConfig parallelscommon.BootScreensConfig `mapstructure:"boot_screen_config" required:"false"`
-	// OCR library to use. Two options are currently supported: "tesseract" and "vision".
-	// "tesseract" uses the Tesseract OCR library to recognize text. A manual installation of -
-	// Tesseract is required for this to work.
-	// "vision" uses the Apple Vision library to recognize text, which is included in macOS. It might -
-	// cause problems in macOS 13 or older VMs.
-	// Defaults to "vision".
-	OCRLibrary string `mapstructure:"ocr_library" required:"false"`
+	common.PackerConfig                   `mapstructure:",squash"`
+	commonsteps.HTTPConfig                `mapstructure:",squash"`
+	bootcommand.BootConfig                `mapstructure:",squash"`
+	parallelscommon.OCRConfig             `mapstructure:",squash"`
+	parallelscommon.ScreenBasedBootConfig `mapstructure:",squash"`
+	parallelscommon.BootKeyboardConfig    `mapstructure:",squash"`
+	parallelscommon.OutputConfig          `mapstructure:",squash"`
+	parallelscommon.HWConfig              `mapstructure:",squash"`
+	parallelscommon.PrlctlConfig          `mapstructure:",squash"`
+	parallelscommon.PrlctlPostConfig      `mapstructure:",squash"`
+	parallelscommon.PrlctlVersionConfig   `mapstructure:",squash"`
+	shutdowncommand.ShutdownConfig        `mapstructure:",squash"`
+	parallelscommon.SSHConfig             `mapstructure:",squash"`
+	parallelscommon.VMConfig              `mapstructure:",squash"`
+	parallelscommon.RegistrationConfig    `mapstructure:",squash"`
+	parallelscommon.DefaultSettingsConfig `mapstructure:",squash"`
+	// The settings applied to the VM at the end of the build, once it is shut
+	// down. See the [Final VM Configuration](#final-vm-configuration) section.
+	FinalVMConfig parallelscommon.FinalVMConfig `mapstructure:"final_vm_config" required:"false"`
+
 	// IPSWConfig is the configuration for the IPSW file
 	IPSWConfig IPSWConfig `mapstructure:",squash"`
 	// The size, in megabytes, of the hard disk to create
@@ -79,8 +71,7 @@ type Config struct {
 	// "packer-BUILDNAME", where "BUILDNAME" is the name of the build.
 	VMName string `mapstructure:"vm_name" required:"false"`
 
-	ctx              interpolate.Context
-	screenConfigsMap map[string]parallelscommon.BootScreenConfig
+	ctx interpolate.Context
 }
 
 func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
@@ -93,6 +84,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"boot_screen_config",
 				"prlctl",
 				"prlctl_post",
 				"parallels_tools_guest_path",
@@ -121,7 +113,11 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	errs = packersdk.MultiErrorAppend(errs, b.config.ShutdownConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.SSHConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.BootKeyboardConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.VMConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.RegistrationConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.DefaultSettingsConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.FinalVMConfig.Prepare(&b.config.ctx)...)
 
 	if b.config.DiskSize == 0 {
 		b.config.DiskSize = 40000
@@ -145,44 +141,19 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 				"will forcibly halt the virtual machine, which may result in data loss.")
 	}
 
-	fmt.Fprintln(os.Stderr, "Screen count is : ", len(b.config.BootScreenConfig))
-
-	emptyScreenCount := 0
-	b.config.screenConfigsMap = make(map[string]parallelscommon.BootScreenConfig)
-	for _, screenConfig := range b.config.BootScreenConfig {
-		errs = packersdk.MultiErrorAppend(errs, screenConfig.Prepare(&b.config.ctx)...)
-		if len(screenConfig.MatchingStrings) == 0 {
-			emptyScreenCount++
-		}
-
-		if screenConfig.ScreenName == "" {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("screen_name should not be empty"))
-			continue
-		}
-
-		if _, exists := b.config.screenConfigsMap[screenConfig.ScreenName]; exists {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("multiple screens with same name: %s", screenConfig.ScreenName))
-			continue
-		}
-		b.config.screenConfigsMap[screenConfig.ScreenName] = screenConfig
-	}
-
-	if emptyScreenCount > 1 {
-		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("more than one empty screen config found."+
-			"only one empty screen config is allowed"))
-	}
-
-	if b.config.OCRLibrary == "" {
-		b.config.OCRLibrary = "vision"
-	} else if b.config.OCRLibrary != "tesseract" && b.config.OCRLibrary != "vision" {
-		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("invalid ocr_library: %s", b.config.OCRLibrary))
-	}
+	errs = packersdk.MultiErrorAppend(errs, b.config.OCRConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.ScreenBasedBootConfig.Prepare(&b.config.ctx, &b.config.OCRConfig)...)
 
 	if b.config.StartupView == "coherence" || b.config.StartupView == "fullscreen" || b.config.StartupView == "modality" {
 		errs = packersdk.MultiErrorAppend(errs,
 			fmt.Errorf("invalid value for startup-view (not supported for macOS VMs): %s. Allowed values are : same, window, headless",
 				b.config.StartupView))
 	}
+	if v := b.config.FinalVMConfig.StartupView; v == "coherence" || v == "fullscreen" || v == "modality" {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("invalid value for final_vm_config startup_view (not supported for macOS VMs): %s. Allowed values are : same, window, headless",
+				v))
+	}
 
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, warnings, errs
@@ -192,6 +163,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	startTime := time.Now()
+
 	// Create the driver that we'll use to communicate with Parallels
 	driver, err := parallelscommon.NewDriver()
 	if err != nil {
@@ -230,12 +203,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			VMName:         b.config.VMName,
 			Ctx:            b.config.ctx,
 			GroupInterval:  b.config.BootConfig.BootGroupInterval,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+			OCR:            b.config.OCRConfig,
+			MacVM:          true,
 		},
 		&parallelscommon.StepScreenBasedBoot{
-			ScreenConfigs: b.config.screenConfigsMap,
-			OCRLibrary:    b.config.OCRLibrary,
-			VmName:        b.config.VMName,
-			Ctx:           b.config.ctx,
+			ScreenConfigs:  b.config.BootScreenConfig,
+			Timeout:        b.config.BootScreenTimeout,
+			ScreenshotsDir: b.config.BootScreenshotsDir,
+			RecordFile:     b.config.BootScreenRecord,
+			OCR:            b.config.OCRConfig,
+			VmName:         b.config.VMName,
+			Ctx:            b.config.ctx,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+			MacVM:          true,
 		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
@@ -268,6 +251,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		},
 	}...)
 
+	steps = append(steps, &parallelscommon.StepApplyFinalVMConfig{
+		Config: b.config.FinalVMConfig,
+	}, &parallelscommon.StepCollectManifest{
+		Source:         b.config.IPSWConfig.IPSWUrls[0],
+		SourceChecksum: b.config.IPSWConfig.IPSWChecksum,
+		StartTime:      startTime,
+	}, &parallelscommon.StepConvertToTemplate{
+		Skip: !b.config.RegisterAsTemplate,
+	})
+
 	// Setup the state bag
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
@@ -294,6 +287,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("Build was halted.")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
+	err = parallelscommon.PruneArtifact(ui, b.config.OutputDir, b.config.ArtifactExclude, b.config.ArtifactKeep)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := map[string]interface{}{
+		"generated_data": state.Get("generated_data"),
+		"manifest":       state.Get("manifest"),
+		"registered":     b.config.KeepRegistered,
+		"template":       b.config.RegisterAsTemplate,
+	}
 	return parallelscommon.NewArtifact(b.config.OutputDir, generatedData)
 }
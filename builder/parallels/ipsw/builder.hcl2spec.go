@@ -29,7 +29,18 @@ type FlatConfig struct {
 	BootGroupInterval         *string                       `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
 	BootWait                  *string                       `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
 	BootCommand               []string                      `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	OCRLibrary                *string                       `mapstructure:"ocr_library" required:"false" cty:"ocr_library" hcl:"ocr_library"`
+	OCRCommand                []string                      `mapstructure:"ocr_command" required:"false" cty:"ocr_command" hcl:"ocr_command"`
+	OCRURL                    *string                       `mapstructure:"ocr_url" required:"false" cty:"ocr_url" hcl:"ocr_url"`
+	BootScreenConfig          []common.FlatBootScreenConfig `mapstructure:"boot_screen_config" required:"false" cty:"boot_screen_config" hcl:"boot_screen_config"`
+	BootScreenTimeout         *string                       `mapstructure:"boot_screen_timeout" required:"false" cty:"boot_screen_timeout" hcl:"boot_screen_timeout"`
+	BootScreenshotsDir        *string                       `mapstructure:"boot_screenshots_dir" required:"false" cty:"boot_screenshots_dir" hcl:"boot_screenshots_dir"`
+	BootScreenRecord          *string                       `mapstructure:"boot_screen_record" required:"false" cty:"boot_screen_record" hcl:"boot_screen_record"`
+	BootKeyboardLayout        *string                       `mapstructure:"boot_keyboard_layout" required:"false" cty:"boot_keyboard_layout" hcl:"boot_keyboard_layout"`
+	BootKeyInterval           *string                       `mapstructure:"boot_key_interval" required:"false" cty:"boot_key_interval" hcl:"boot_key_interval"`
 	OutputDir                 *string                       `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	ArtifactExclude           []string                      `mapstructure:"artifact_exclude" required:"false" cty:"artifact_exclude" hcl:"artifact_exclude"`
+	ArtifactKeep              []string                      `mapstructure:"artifact_keep" required:"false" cty:"artifact_keep" hcl:"artifact_keep"`
 	CpuCount                  *int                          `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
 	MemorySize                *int                          `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
 	Sound                     *bool                         `mapstructure:"sound" required:"false" cty:"sound" hcl:"sound"`
@@ -90,8 +101,11 @@ type FlatConfig struct {
 	WinRMUseNTLM              *bool                         `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
 	StartupView               *string                       `mapstructure:"startup_view" required:"false" cty:"startup_view" hcl:"startup_view"`
 	OnWindowClose             *string                       `mapstructure:"on_window_close" required:"false" cty:"on_window_close" hcl:"on_window_close"`
-	BootScreenConfig          []common.FlatBootScreenConfig `mapstructure:"boot_screen_config" required:"false" cty:"boot_screen_config" hcl:"boot_screen_config"`
-	OCRLibrary                *string                       `mapstructure:"ocr_library" required:"false" cty:"ocr_library" hcl:"ocr_library"`
+	KeepRegistered            *bool                         `mapstructure:"keep_registered" required:"false" cty:"keep_registered" hcl:"keep_registered"`
+	RegisterAsTemplate        *bool                         `mapstructure:"register_as_template" required:"false" cty:"register_as_template" hcl:"register_as_template"`
+	DefaultSettingsOverrides  map[string]string             `mapstructure:"default_settings_overrides" required:"false" cty:"default_settings_overrides" hcl:"default_settings_overrides"`
+	SkipDefaultSettings       *bool                         `mapstructure:"skip_default_settings" required:"false" cty:"skip_default_settings" hcl:"skip_default_settings"`
+	FinalVMConfig             *common.FlatFinalVMConfig     `mapstructure:"final_vm_config" required:"false" cty:"final_vm_config" hcl:"final_vm_config"`
 	IPSWChecksum              *string                       `mapstructure:"ipsw_checksum" required:"true" cty:"ipsw_checksum" hcl:"ipsw_checksum"`
 	RawSingleIPSWUrl          *string                       `mapstructure:"ipsw_url" required:"true" cty:"ipsw_url" hcl:"ipsw_url"`
 	IPSWUrls                  []string                      `mapstructure:"ipsw_urls" cty:"ipsw_urls" hcl:"ipsw_urls"`
@@ -131,7 +145,18 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
 		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"ocr_library":                  &hcldec.AttrSpec{Name: "ocr_library", Type: cty.String, Required: false},
+		"ocr_command":                  &hcldec.AttrSpec{Name: "ocr_command", Type: cty.List(cty.String), Required: false},
+		"ocr_url":                      &hcldec.AttrSpec{Name: "ocr_url", Type: cty.String, Required: false},
+		"boot_screen_config":           &hcldec.BlockListSpec{TypeName: "boot_screen_config", Nested: hcldec.ObjectSpec((*common.FlatBootScreenConfig)(nil).HCL2Spec())},
+		"boot_screen_timeout":          &hcldec.AttrSpec{Name: "boot_screen_timeout", Type: cty.String, Required: false},
+		"boot_screenshots_dir":         &hcldec.AttrSpec{Name: "boot_screenshots_dir", Type: cty.String, Required: false},
+		"boot_screen_record":           &hcldec.AttrSpec{Name: "boot_screen_record", Type: cty.String, Required: false},
+		"boot_keyboard_layout":         &hcldec.AttrSpec{Name: "boot_keyboard_layout", Type: cty.String, Required: false},
+		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
 		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"artifact_exclude":             &hcldec.AttrSpec{Name: "artifact_exclude", Type: cty.List(cty.String), Required: false},
+		"artifact_keep":                &hcldec.AttrSpec{Name: "artifact_keep", Type: cty.List(cty.String), Required: false},
 		"cpus":                         &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
 		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
 		"sound":                        &hcldec.AttrSpec{Name: "sound", Type: cty.Bool, Required: false},
@@ -192,8 +217,11 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
 		"startup_view":                 &hcldec.AttrSpec{Name: "startup_view", Type: cty.String, Required: false},
 		"on_window_close":              &hcldec.AttrSpec{Name: "on_window_close", Type: cty.String, Required: false},
-		"boot_screen_config":           &hcldec.BlockListSpec{TypeName: "boot_screen_config", Nested: hcldec.ObjectSpec((*common.FlatBootScreenConfig)(nil).HCL2Spec())},
-		"ocr_library":                  &hcldec.AttrSpec{Name: "ocr_library", Type: cty.String, Required: false},
+		"keep_registered":              &hcldec.AttrSpec{Name: "keep_registered", Type: cty.Bool, Required: false},
+		"register_as_template":         &hcldec.AttrSpec{Name: "register_as_template", Type: cty.Bool, Required: false},
+		"default_settings_overrides":   &hcldec.AttrSpec{Name: "default_settings_overrides", Type: cty.Map(cty.String), Required: false},
+		"skip_default_settings":        &hcldec.AttrSpec{Name: "skip_default_settings", Type: cty.Bool, Required: false},
+		"final_vm_config":              &hcldec.BlockSpec{TypeName: "final_vm_config", Nested: hcldec.ObjectSpec((*common.FlatFinalVMConfig)(nil).HCL2Spec())},
 		"ipsw_checksum":                &hcldec.AttrSpec{Name: "ipsw_checksum", Type: cty.String, Required: false},
 		"ipsw_url":                     &hcldec.AttrSpec{Name: "ipsw_url", Type: cty.String, Required: false},
 		"ipsw_urls":                    &hcldec.AttrSpec{Name: "ipsw_urls", Type: cty.List(cty.String), Required: false},
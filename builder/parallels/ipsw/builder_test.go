@@ -91,3 +91,35 @@ func TestBuilderPrepare_InvalidKey(t *testing.T) {
 		t.Fatal("should have error")
 	}
 }
+
+func TestBuilderPrepare_BootScreenConfig(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// The boot commands of the screens are rendered when typed
+	bootCommand := `<click {{ (.Texts.Find "Next").CenterX }},{{ (.Texts.Find "Next").CenterY }}>`
+	config["boot_screen_config"] = []map[string]interface{}{
+		{
+			"screen_name":      "language",
+			"matching_strings": []string{"Next"},
+			"region":           []float64{0.25, 0, 0.75, 1},
+			"boot_command":     []string{bootCommand},
+		},
+	}
+	_, warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if command := b.config.BootScreenConfig[0].FlatBootCommand(); command != bootCommand {
+		t.Fatalf("bad boot command: %s", command)
+	}
+
+	config["ocr_library"] = "image"
+	b = Builder{}
+	if _, _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
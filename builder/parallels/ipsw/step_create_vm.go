@@ -59,12 +59,22 @@ func (s *stepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		}
 	}
 
-	ui.Say("Applying default settings...")
-	if err := driver.SetDefaultConfiguration(name); err != nil {
-		err := fmt.Errorf("Error VM configuration: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	parallelscommon.CaptureVMSettings(state, driver, name)
+
+	if config.SkipDefaultSettings {
+		ui.Say("Skipping default settings...")
+	} else {
+		ui.Say("Applying default settings...")
+		warnings, err := driver.SetDefaultConfiguration(name, config.DefaultSettingsOverrides)
+		if err != nil {
+			err := fmt.Errorf("Error VM configuration: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		for _, warning := range warnings {
+			ui.Message(fmt.Sprintf("Warning: %s", warning))
+		}
 	}
 
 	prlctlCurrVersionStr, verErr := driver.Version()
@@ -102,9 +112,15 @@ func (s *stepCreateVM) Cleanup(state multistep.StateBag) {
 		return
 	}
 
+	config := state.Get("config").(*Config)
 	driver := state.Get("driver").(parallelscommon.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	if parallelscommon.KeepVMRegistered(state, config.KeepRegistered) {
+		ui.Say(fmt.Sprintf("Keeping the virtual machine registered: %s", s.vmName))
+		return
+	}
+
 	ui.Say("Unregistering virtual machine...")
 	if err := driver.Prlctl("unregister", s.vmName); err != nil {
 		ui.Error(fmt.Sprintf("Error unregistering virtual machine: %s", err))
@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	parallelscommon "github.com/Parallels/packer-plugin-parallels/builder/parallels/common"
 	"github.com/hashicorp/hcl/v2/hcldec"
@@ -32,21 +33,29 @@ type Builder struct {
 }
 
 type Config struct {
-	common.PackerConfig                 `mapstructure:",squash"`
-	commonsteps.HTTPConfig              `mapstructure:",squash"`
-	commonsteps.ISOConfig               `mapstructure:",squash"`
-	commonsteps.FloppyConfig            `mapstructure:",squash"`
-	commonsteps.CDConfig                `mapstructure:",squash"`
-	bootcommand.BootConfig              `mapstructure:",squash"`
-	parallelscommon.OutputConfig        `mapstructure:",squash"`
-	parallelscommon.HWConfig            `mapstructure:",squash"`
-	parallelscommon.PrlctlConfig        `mapstructure:",squash"`
-	parallelscommon.PrlctlPostConfig    `mapstructure:",squash"`
-	parallelscommon.PrlctlVersionConfig `mapstructure:",squash"`
-	shutdowncommand.ShutdownConfig      `mapstructure:",squash"`
-	parallelscommon.SSHConfig           `mapstructure:",squash"`
-	parallelscommon.ToolsConfig         `mapstructure:",squash"`
-	parallelscommon.VMConfig            `mapstructure:",squash"`
+	common.PackerConfig                   `mapstructure:",squash"`
+	commonsteps.HTTPConfig                `mapstructure:",squash"`
+	commonsteps.ISOConfig                 `mapstructure:",squash"`
+	commonsteps.FloppyConfig              `mapstructure:",squash"`
+	commonsteps.CDConfig                  `mapstructure:",squash"`
+	bootcommand.BootConfig                `mapstructure:",squash"`
+	parallelscommon.OCRConfig             `mapstructure:",squash"`
+	parallelscommon.ScreenBasedBootConfig `mapstructure:",squash"`
+	parallelscommon.BootKeyboardConfig    `mapstructure:",squash"`
+	parallelscommon.OutputConfig          `mapstructure:",squash"`
+	parallelscommon.HWConfig              `mapstructure:",squash"`
+	parallelscommon.PrlctlConfig          `mapstructure:",squash"`
+	parallelscommon.PrlctlPostConfig      `mapstructure:",squash"`
+	parallelscommon.PrlctlVersionConfig   `mapstructure:",squash"`
+	shutdowncommand.ShutdownConfig        `mapstructure:",squash"`
+	parallelscommon.SSHConfig             `mapstructure:",squash"`
+	parallelscommon.ToolsConfig           `mapstructure:",squash"`
+	parallelscommon.VMConfig              `mapstructure:",squash"`
+	parallelscommon.RegistrationConfig    `mapstructure:",squash"`
+	parallelscommon.DefaultSettingsConfig `mapstructure:",squash"`
+	// The settings applied to the VM at the end of the build, once it is shut
+	// down. See the [Final VM Configuration](#final-vm-configuration) section.
+	FinalVMConfig parallelscommon.FinalVMConfig `mapstructure:"final_vm_config" required:"false"`
 	// The size, in megabytes, of the hard disk to create
 	// for the VM. By default, this is 40000 (about 40 GB).
 	DiskSize uint `mapstructure:"disk_size" required:"false"`
@@ -100,6 +109,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"boot_screen_config",
 				"prlctl",
 				"prlctl_post",
 				"parallels_tools_guest_path",
@@ -130,7 +140,13 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	errs = packersdk.MultiErrorAppend(errs, b.config.SSHConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.ToolsConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.BootKeyboardConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.OCRConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.ScreenBasedBootConfig.Prepare(&b.config.ctx, &b.config.OCRConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.VMConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.RegistrationConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.DefaultSettingsConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, b.config.FinalVMConfig.Prepare(&b.config.ctx)...)
 
 	if b.config.DiskSize == 0 {
 		b.config.DiskSize = 40000
@@ -189,6 +205,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	startTime := time.Now()
+
 	// Create the driver that we'll use to communicate with Parallels
 	driver, err := parallelscommon.NewDriver()
 	if err != nil {
@@ -247,6 +265,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			VMName:         b.config.VMName,
 			Ctx:            b.config.ctx,
 			GroupInterval:  b.config.BootConfig.BootGroupInterval,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+			OCR:            b.config.OCRConfig,
+		},
+		&parallelscommon.StepScreenBasedBoot{
+			ScreenConfigs:  b.config.BootScreenConfig,
+			Timeout:        b.config.BootScreenTimeout,
+			ScreenshotsDir: b.config.BootScreenshotsDir,
+			RecordFile:     b.config.BootScreenRecord,
+			OCR:            b.config.OCRConfig,
+			VmName:         b.config.VMName,
+			Ctx:            b.config.ctx,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
 		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
@@ -279,6 +311,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		},
 	}
 
+	steps = append(steps, &parallelscommon.StepApplyFinalVMConfig{
+		Config: b.config.FinalVMConfig,
+	}, &parallelscommon.StepCollectManifest{
+		Source:         b.config.ISOUrls[0],
+		SourceChecksum: b.config.ISOChecksum,
+		StartTime:      startTime,
+	}, &parallelscommon.StepConvertToTemplate{
+		Skip: !b.config.RegisterAsTemplate,
+	})
+
 	// Setup the state bag
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
@@ -305,6 +347,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("Build was halted.")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
+	err = parallelscommon.PruneArtifact(ui, b.config.OutputDir, b.config.ArtifactExclude, b.config.ArtifactKeep)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := map[string]interface{}{
+		"generated_data": state.Get("generated_data"),
+		"manifest":       state.Get("manifest"),
+		"registered":     b.config.KeepRegistered,
+		"template":       b.config.RegisterAsTemplate,
+	}
 	return parallelscommon.NewArtifact(b.config.OutputDir, generatedData)
 }
@@ -243,3 +243,57 @@ func TestBuilderPrepare_InvalidKey(t *testing.T) {
 		t.Fatal("should have error")
 	}
 }
+
+func TestBuilderPrepare_BootScreenConfig(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// The boot commands of the screens are rendered when typed
+	bootCommand := `<click {{ (.Texts.Find "Install").CenterX }},{{ (.Texts.Find "Install").CenterY }}>`
+	config["ocr_library"] = "tesseract"
+	config["boot_command"] = []string{"<enter>"}
+	config["boot_screen_config"] = []map[string]interface{}{
+		{
+			"screen_name":      "installer",
+			"matching_strings": []string{"Install"},
+			"boot_command":     []string{bootCommand},
+			"is_last_screen":   true,
+		},
+	}
+	_, warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if command := b.config.BootScreenConfig[0].FlatBootCommand(); command != bootCommand {
+		t.Fatalf("bad boot command: %s", command)
+	}
+
+	config["boot_screen_timeout"] = "-1m"
+	b = Builder{}
+	if _, _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The next screens must lead to a last screen
+	config["boot_screen_timeout"] = "1h"
+	config["boot_screen_config"] = []map[string]interface{}{
+		{
+			"screen_name":      "installer",
+			"matching_strings": []string{"Install"},
+			"next_screens":     []string{"retry"},
+		},
+		{
+			"screen_name":      "retry",
+			"matching_strings": []string{"Retry"},
+			"next_screens":     []string{"installer"},
+			"max_visits":       3,
+		},
+	}
+	b = Builder{}
+	if _, _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
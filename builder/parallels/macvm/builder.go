@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	parallelscommon "github.com/Parallels/packer-plugin-parallels/builder/parallels/common"
 	"github.com/hashicorp/hcl/v2/hcldec"
@@ -37,6 +38,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 // Run executes a Packer build and returns a packersdk.Artifact representing
 // a Parallels appliance.
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	startTime := time.Now()
+
 	// Create the driver that we'll use to communicate with Parallels
 	driver, err := parallelscommon.NewDriver()
 	if err != nil {
@@ -52,12 +55,6 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("ui", ui)
 	state.Put("http_port", 0)
 
-	// Moving to a map for better processing
-	screenConfigsMap := make(map[string]parallelscommon.BootScreenConfig)
-	for _, screenConfig := range b.config.BootScreenConfig {
-		screenConfigsMap[screenConfig.ScreenName] = screenConfig
-	}
-
 	// Build the steps.
 	steps := []multistep.Step{
 		&parallelscommon.StepOutputDir{
@@ -65,10 +62,14 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Path:  b.config.OutputDir,
 		},
 		&parallelscommon.StepImport{
-			Name:        b.config.VMName,
-			SourcePath:  b.config.SourcePath,
-			OutputDir:   b.config.OutputDir,
-			ReassignMAC: b.config.ReassignMAC,
+			Name:           b.config.VMName,
+			SourcePath:     b.config.SourcePath,
+			SourceVM:       b.config.SourceVM,
+			SourceSnapshot: b.config.SourceSnapshot,
+			OutputDir:      b.config.OutputDir,
+			ReassignMAC:    b.config.ReassignMAC,
+			LinkedClone:    b.config.IsLinked(),
+			KeepRegistered: b.config.KeepRegistered,
 		},
 		&parallelscommon.StepApplyVMConfig{
 			CustomVMConfig: b.config.VMConfig,
@@ -85,12 +86,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			VMName:         b.config.VMName,
 			Ctx:            b.config.ctx,
 			GroupInterval:  b.config.BootConfig.BootGroupInterval,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+			OCR:            b.config.OCRConfig,
+			MacVM:          true,
 		},
 		&parallelscommon.StepScreenBasedBoot{
-			ScreenConfigs: screenConfigsMap,
-			OCRLibrary:    b.config.OCRLibrary,
-			VmName:        b.config.VMName,
-			Ctx:           b.config.ctx,
+			ScreenConfigs:  b.config.BootScreenConfig,
+			Timeout:        b.config.BootScreenTimeout,
+			ScreenshotsDir: b.config.BootScreenshotsDir,
+			RecordFile:     b.config.BootScreenRecord,
+			OCR:            b.config.OCRConfig,
+			VmName:         b.config.VMName,
+			Ctx:            b.config.ctx,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+			MacVM:          true,
 		},
 		&communicator.StepConnect{
 			Config:    &b.config.SSHConfig.Comm,
@@ -121,8 +132,43 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Commands: b.config.PrlctlPost,
 			Ctx:      b.config.ctx,
 		},
+		&parallelscommon.StepFlattenDisk{
+			Skip: !b.config.FlattenLinkedClone,
+		},
 	}...)
 
+	if b.config.SourceURL != "" {
+		// Download and extract the source VM before anything else
+		steps = append([]multistep.Step{
+			&commonsteps.StepDownload{
+				Checksum:    b.config.SourceChecksum,
+				Description: "source VM",
+				Extension:   parallelscommon.SourceArchiveExtension(b.config.SourceURL),
+				ResultKey:   "source_archive_path",
+				Url:         []string{b.config.DownloadURL()},
+			},
+			&parallelscommon.StepExtractSource{
+				Checksum: b.config.SourceChecksum,
+			},
+		}, steps...)
+	}
+
+	source := b.config.SourcePath
+	if b.config.SourceURL != "" {
+		source = b.config.SourceURL
+	} else if b.config.SourceVM != "" {
+		source = b.config.SourceVM
+	}
+	steps = append(steps, &parallelscommon.StepApplyFinalVMConfig{
+		Config: b.config.FinalVMConfig,
+	}, &parallelscommon.StepCollectManifest{
+		Source:         source,
+		SourceChecksum: b.config.SourceChecksum,
+		StartTime:      startTime,
+	}, &parallelscommon.StepConvertToTemplate{
+		Skip: !b.config.RegisterAsTemplate,
+	})
+
 	// Run the steps.
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
@@ -141,7 +187,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("Build was halted.")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
+	err = parallelscommon.PruneArtifact(ui, b.config.OutputDir, b.config.ArtifactExclude, b.config.ArtifactKeep)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := map[string]interface{}{
+		"generated_data": state.Get("generated_data"),
+		"manifest":       state.Get("manifest"),
+		"registered":     b.config.KeepRegistered,
+		"template":       b.config.RegisterAsTemplate,
+	}
+	if parent, ok := state.GetOk("linked_clone_parent"); ok {
+		generatedData["linked_clone_parent"] = parent
+	}
 	return parallelscommon.NewArtifact(b.config.OutputDir, generatedData)
 }
 
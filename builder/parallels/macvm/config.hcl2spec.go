@@ -20,6 +20,8 @@ type FlatConfig struct {
 	PackerUserVars            map[string]string             `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
 	PackerSensitiveVars       []string                      `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
 	OutputDir                 *string                       `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	ArtifactExclude           []string                      `mapstructure:"artifact_exclude" required:"false" cty:"artifact_exclude" hcl:"artifact_exclude"`
+	ArtifactKeep              []string                      `mapstructure:"artifact_keep" required:"false" cty:"artifact_keep" hcl:"artifact_keep"`
 	Prlctl                    [][]string                    `mapstructure:"prlctl" required:"false" cty:"prlctl" hcl:"prlctl"`
 	PrlctlPost                [][]string                    `mapstructure:"prlctl_post" required:"false" cty:"prlctl_post" hcl:"prlctl_post"`
 	PrlctlVersionFile         *string                       `mapstructure:"prlctl_version_file" required:"false" cty:"prlctl_version_file" hcl:"prlctl_version_file"`
@@ -77,11 +79,27 @@ type FlatConfig struct {
 	BootGroupInterval         *string                       `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
 	BootWait                  *string                       `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
 	BootCommand               []string                      `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	OCRLibrary                *string                       `mapstructure:"ocr_library" required:"false" cty:"ocr_library" hcl:"ocr_library"`
+	OCRCommand                []string                      `mapstructure:"ocr_command" required:"false" cty:"ocr_command" hcl:"ocr_command"`
+	OCRURL                    *string                       `mapstructure:"ocr_url" required:"false" cty:"ocr_url" hcl:"ocr_url"`
+	BootScreenConfig          []common.FlatBootScreenConfig `mapstructure:"boot_screen_config" required:"false" cty:"boot_screen_config" hcl:"boot_screen_config"`
+	BootScreenTimeout         *string                       `mapstructure:"boot_screen_timeout" required:"false" cty:"boot_screen_timeout" hcl:"boot_screen_timeout"`
+	BootScreenshotsDir        *string                       `mapstructure:"boot_screenshots_dir" required:"false" cty:"boot_screenshots_dir" hcl:"boot_screenshots_dir"`
+	BootScreenRecord          *string                       `mapstructure:"boot_screen_record" required:"false" cty:"boot_screen_record" hcl:"boot_screen_record"`
+	BootKeyboardLayout        *string                       `mapstructure:"boot_keyboard_layout" required:"false" cty:"boot_keyboard_layout" hcl:"boot_keyboard_layout"`
+	BootKeyInterval           *string                       `mapstructure:"boot_key_interval" required:"false" cty:"boot_key_interval" hcl:"boot_key_interval"`
 	StartupView               *string                       `mapstructure:"startup_view" required:"false" cty:"startup_view" hcl:"startup_view"`
 	OnWindowClose             *string                       `mapstructure:"on_window_close" required:"false" cty:"on_window_close" hcl:"on_window_close"`
-	BootScreenConfig          []common.FlatBootScreenConfig `mapstructure:"boot_screen_config" required:"false" cty:"boot_screen_config" hcl:"boot_screen_config"`
-	OCRLibrary                *string                       `mapstructure:"ocr_library" required:"false" cty:"ocr_library" hcl:"ocr_library"`
+	KeepRegistered            *bool                         `mapstructure:"keep_registered" required:"false" cty:"keep_registered" hcl:"keep_registered"`
+	RegisterAsTemplate        *bool                         `mapstructure:"register_as_template" required:"false" cty:"register_as_template" hcl:"register_as_template"`
+	FinalVMConfig             *common.FlatFinalVMConfig     `mapstructure:"final_vm_config" required:"false" cty:"final_vm_config" hcl:"final_vm_config"`
+	CloneMode                 *string                       `mapstructure:"clone_mode" required:"false" cty:"clone_mode" hcl:"clone_mode"`
+	FlattenLinkedClone        *bool                         `mapstructure:"flatten_linked_clone" required:"false" cty:"flatten_linked_clone" hcl:"flatten_linked_clone"`
+	SourceURL                 *string                       `mapstructure:"source_url" required:"false" cty:"source_url" hcl:"source_url"`
+	SourceChecksum            *string                       `mapstructure:"source_checksum" required:"false" cty:"source_checksum" hcl:"source_checksum"`
 	SourcePath                *string                       `mapstructure:"source_path" required:"true" cty:"source_path" hcl:"source_path"`
+	SourceVM                  *string                       `mapstructure:"source_vm" required:"false" cty:"source_vm" hcl:"source_vm"`
+	SourceSnapshot            *string                       `mapstructure:"source_snapshot" required:"false" cty:"source_snapshot" hcl:"source_snapshot"`
 	VMName                    *string                       `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
 	ReassignMAC               *bool                         `mapstructure:"reassign_mac" required:"false" cty:"reassign_mac" hcl:"reassign_mac"`
 }
@@ -107,6 +125,8 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
 		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
 		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"artifact_exclude":             &hcldec.AttrSpec{Name: "artifact_exclude", Type: cty.List(cty.String), Required: false},
+		"artifact_keep":                &hcldec.AttrSpec{Name: "artifact_keep", Type: cty.List(cty.String), Required: false},
 		"prlctl":                       &hcldec.AttrSpec{Name: "prlctl", Type: cty.List(cty.List(cty.String)), Required: false},
 		"prlctl_post":                  &hcldec.AttrSpec{Name: "prlctl_post", Type: cty.List(cty.List(cty.String)), Required: false},
 		"prlctl_version_file":          &hcldec.AttrSpec{Name: "prlctl_version_file", Type: cty.String, Required: false},
@@ -164,11 +184,27 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
 		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"ocr_library":                  &hcldec.AttrSpec{Name: "ocr_library", Type: cty.String, Required: false},
+		"ocr_command":                  &hcldec.AttrSpec{Name: "ocr_command", Type: cty.List(cty.String), Required: false},
+		"ocr_url":                      &hcldec.AttrSpec{Name: "ocr_url", Type: cty.String, Required: false},
+		"boot_screen_config":           &hcldec.BlockListSpec{TypeName: "boot_screen_config", Nested: hcldec.ObjectSpec((*common.FlatBootScreenConfig)(nil).HCL2Spec())},
+		"boot_screen_timeout":          &hcldec.AttrSpec{Name: "boot_screen_timeout", Type: cty.String, Required: false},
+		"boot_screenshots_dir":         &hcldec.AttrSpec{Name: "boot_screenshots_dir", Type: cty.String, Required: false},
+		"boot_screen_record":           &hcldec.AttrSpec{Name: "boot_screen_record", Type: cty.String, Required: false},
+		"boot_keyboard_layout":         &hcldec.AttrSpec{Name: "boot_keyboard_layout", Type: cty.String, Required: false},
+		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
 		"startup_view":                 &hcldec.AttrSpec{Name: "startup_view", Type: cty.String, Required: false},
 		"on_window_close":              &hcldec.AttrSpec{Name: "on_window_close", Type: cty.String, Required: false},
-		"boot_screen_config":           &hcldec.BlockListSpec{TypeName: "boot_screen_config", Nested: hcldec.ObjectSpec((*common.FlatBootScreenConfig)(nil).HCL2Spec())},
-		"ocr_library":                  &hcldec.AttrSpec{Name: "ocr_library", Type: cty.String, Required: false},
+		"keep_registered":              &hcldec.AttrSpec{Name: "keep_registered", Type: cty.Bool, Required: false},
+		"register_as_template":         &hcldec.AttrSpec{Name: "register_as_template", Type: cty.Bool, Required: false},
+		"final_vm_config":              &hcldec.BlockSpec{TypeName: "final_vm_config", Nested: hcldec.ObjectSpec((*common.FlatFinalVMConfig)(nil).HCL2Spec())},
+		"clone_mode":                   &hcldec.AttrSpec{Name: "clone_mode", Type: cty.String, Required: false},
+		"flatten_linked_clone":         &hcldec.AttrSpec{Name: "flatten_linked_clone", Type: cty.Bool, Required: false},
+		"source_url":                   &hcldec.AttrSpec{Name: "source_url", Type: cty.String, Required: false},
+		"source_checksum":              &hcldec.AttrSpec{Name: "source_checksum", Type: cty.String, Required: false},
 		"source_path":                  &hcldec.AttrSpec{Name: "source_path", Type: cty.String, Required: false},
+		"source_vm":                    &hcldec.AttrSpec{Name: "source_vm", Type: cty.String, Required: false},
+		"source_snapshot":              &hcldec.AttrSpec{Name: "source_snapshot", Type: cty.String, Required: false},
 		"vm_name":                      &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
 		"reassign_mac":                 &hcldec.AttrSpec{Name: "reassign_mac", Type: cty.Bool, Required: false},
 	}
@@ -87,3 +87,30 @@ func TestNewConfig_shutdown_timeout(t *testing.T) {
 	warns, errs = (&Config{}).Prepare(cfg)
 	testConfigOk(t, warns, errs)
 }
+
+func TestNewConfig_linkedCloneSourceURL(t *testing.T) {
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_url"] = "https://example.com/ubuntu.macvm.tar.gz"
+	c["source_checksum"] = "sha256:6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+	c["clone_mode"] = "linked"
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	// The extracted source is only needed until the clone is flattened
+	c["flatten_linked_clone"] = true
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+}
+
+func TestNewConfig_finalStartupView(t *testing.T) {
+	// macOS VMs don't support Coherence
+	c := testConfig(t)
+	c["final_vm_config"] = map[string]interface{}{"startup_view": "coherence"}
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	c["final_vm_config"] = map[string]interface{}{"startup_view": "window"}
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+}
@@ -8,7 +8,6 @@ package pvm
 
 import (
 	"fmt"
-	"os"
 
 	parallelscommon "github.com/Parallels/packer-plugin-parallels/builder/parallels/common"
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -22,21 +21,27 @@ import (
 
 // Config is the configuration structure for the builder.
 type Config struct {
-	common.PackerConfig                 `mapstructure:",squash"`
-	commonsteps.FloppyConfig            `mapstructure:",squash"`
-	commonsteps.CDConfig                `mapstructure:",squash"`
-	parallelscommon.OutputConfig        `mapstructure:",squash"`
-	parallelscommon.PrlctlConfig        `mapstructure:",squash"`
-	parallelscommon.PrlctlPostConfig    `mapstructure:",squash"`
-	parallelscommon.PrlctlVersionConfig `mapstructure:",squash"`
-	parallelscommon.SSHConfig           `mapstructure:",squash"`
-	shutdowncommand.ShutdownConfig      `mapstructure:",squash"`
-	bootcommand.BootConfig              `mapstructure:",squash"`
-	parallelscommon.ToolsConfig         `mapstructure:",squash"`
-	parallelscommon.VMConfig            `mapstructure:",squash"`
-	// The path to a PVM directory that acts as the source
-	// of this build.
-	SourcePath string `mapstructure:"source_path" required:"true"`
+	common.PackerConfig                   `mapstructure:",squash"`
+	commonsteps.FloppyConfig              `mapstructure:",squash"`
+	commonsteps.CDConfig                  `mapstructure:",squash"`
+	parallelscommon.OutputConfig          `mapstructure:",squash"`
+	parallelscommon.PrlctlConfig          `mapstructure:",squash"`
+	parallelscommon.PrlctlPostConfig      `mapstructure:",squash"`
+	parallelscommon.PrlctlVersionConfig   `mapstructure:",squash"`
+	parallelscommon.SSHConfig             `mapstructure:",squash"`
+	shutdowncommand.ShutdownConfig        `mapstructure:",squash"`
+	bootcommand.BootConfig                `mapstructure:",squash"`
+	parallelscommon.OCRConfig             `mapstructure:",squash"`
+	parallelscommon.ScreenBasedBootConfig `mapstructure:",squash"`
+	parallelscommon.BootKeyboardConfig    `mapstructure:",squash"`
+	parallelscommon.ToolsConfig           `mapstructure:",squash"`
+	parallelscommon.VMConfig              `mapstructure:",squash"`
+	parallelscommon.RegistrationConfig    `mapstructure:",squash"`
+	// The settings applied to the VM at the end of the build, once it is shut
+	// down. See the [Final VM Configuration](#final-vm-configuration) section.
+	FinalVMConfig                parallelscommon.FinalVMConfig `mapstructure:"final_vm_config" required:"false"`
+	parallelscommon.CloneConfig  `mapstructure:",squash"`
+	parallelscommon.SourceConfig `mapstructure:",squash"`
 	// Virtual disk image is compacted at the end of
 	// the build process using prl_disk_tool utility (except for the case that
 	// disk_type is set to plain). In certain rare cases, this might corrupt
@@ -63,6 +68,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"boot_screen_config",
 				"prlctl",
 				"prlctl_post",
 				"parallels_tools_guest_path",
@@ -85,22 +91,24 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packersdk.MultiErrorAppend(errs, c.PrlctlPostConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.PrlctlVersionConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.BootKeyboardConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.OCRConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ScreenBasedBootConfig.Prepare(&c.ctx, &c.OCRConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.SSHConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ToolsConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.VMConfig.Prepare(&c.ctx)...)
-
-	if c.SourcePath == "" {
-		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_path is required"))
-	} else {
-		if _, err := os.Stat(c.SourcePath); err != nil {
-			errs = packersdk.MultiErrorAppend(errs,
-				fmt.Errorf("source_path is invalid: %s", err))
-		}
-	}
+	errs = packersdk.MultiErrorAppend(errs, c.RegistrationConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.FinalVMConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.CloneConfig.Prepare(&c.ctx)...)
 
 	// Warnings
 	var warnings []string
+
+	sourceWarnings, sourceErrs := c.SourceConfig.Prepare(&c.ctx, c.VMName, &c.CloneConfig)
+	warnings = append(warnings, sourceWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, sourceErrs...)
+
 	if c.ShutdownCommand == "" {
 		warnings = append(warnings,
 			"A shutdown_command was not specified. Without a shutdown command, Packer\n"+
@@ -75,6 +75,71 @@ func TestNewConfig_sourcePath(t *testing.T) {
 	testConfigOk(t, warns, errs)
 }
 
+func TestNewConfig_sourceURL(t *testing.T) {
+	// Good
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_url"] = "https://example.com/ubuntu.pvm.tar.gz"
+	c["source_checksum"] = "sha256:6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+
+	// Bad, both source_path and source_url
+	c = testConfig(t)
+	c["source_url"] = "https://example.com/ubuntu.pvm.tar.gz"
+	c["source_checksum"] = "sha256:6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	// Bad, no checksum
+	c = testConfig(t)
+	delete(c, "source_path")
+	c["source_url"] = "https://example.com/ubuntu.pvm.tar.gz"
+	_, errs = (&Config{}).Prepare(c)
+	if errs == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestNewConfig_sourceVM(t *testing.T) {
+	// Good
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+
+	// Good, with a snapshot
+	c = testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	c["source_snapshot"] = "clean"
+	c["clone_mode"] = "linked"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+
+	// Bad, both source_path and source_vm
+	c = testConfig(t)
+	c["source_vm"] = "base"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	// Bad, snapshot of a full clone
+	c = testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	c["source_snapshot"] = "clean"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	// Bad, snapshot without source_vm
+	c = testConfig(t)
+	c["source_snapshot"] = "clean"
+	c["clone_mode"] = "linked"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+}
+
 func TestNewConfig_FloppyFiles(t *testing.T) {
 	c := testConfig(t)
 	floppies_path := "testdata/floppies"
@@ -114,3 +179,53 @@ func TestNewConfig_shutdown_timeout(t *testing.T) {
 	warns, errs = (&Config{}).Prepare(cfg)
 	testConfigOk(t, warns, errs)
 }
+
+func TestNewConfig_cloneMode(t *testing.T) {
+	c := testConfig(t)
+	c["clone_mode"] = "linked"
+	c["flatten_linked_clone"] = true
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+
+	c = testConfig(t)
+	c["clone_mode"] = "foo"
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+}
+
+func TestNewConfig_bootScreenConfig(t *testing.T) {
+	c := testConfig(t)
+	c["ocr_library"] = "tesseract"
+	c["boot_screen_config"] = []map[string]interface{}{
+		{
+			"screen_name":      "login",
+			"matching_strings": []string{"login:"},
+			"boot_command":     []string{"root<enter>"},
+			"is_last_screen":   true,
+		},
+	}
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+
+	c["boot_screen_config"] = []map[string]interface{}{
+		{"screen_name": "login", "matching_strings": []string{"login:"}},
+		{"screen_name": "login", "matching_strings": []string{"Password:"}},
+	}
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+}
+
+func TestNewConfig_linkedCloneSourceURL(t *testing.T) {
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_url"] = "https://example.com/ubuntu.pvm.tar.gz"
+	c["source_checksum"] = "sha256:6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+	c["clone_mode"] = "linked"
+	warns, errs := (&Config{}).Prepare(c)
+	testConfigErr(t, warns, errs)
+
+	// The extracted source is only needed until the clone is flattened
+	c["flatten_linked_clone"] = true
+	warns, errs = (&Config{}).Prepare(c)
+	testConfigOk(t, warns, errs)
+}